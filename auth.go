@@ -0,0 +1,308 @@
+package httpclient
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// BasicAuth is a username and password sent with the Basic scheme.
+type BasicAuth struct {
+	Username string
+	Password string
+}
+
+// APIKey is a key sent in a header, or in the query when InQuery is set.
+type APIKey struct {
+	Name    string
+	Value   string
+	InQuery bool
+}
+
+func (req *HttpRequest) BasicAuth(username, password string) *HttpRequest {
+	credentials := base64.StdEncoding.EncodeToString([]byte(username + ":" + password))
+	return req.Head("Authorization", "Basic "+credentials)
+}
+
+func (req *HttpRequest) BearerToken(token string) *HttpRequest {
+	return req.Head("Authorization", "Bearer "+token)
+}
+
+// APIKey sends the key in the header name, it is not sent on to another origin.
+func (req *HttpRequest) APIKey(name, value string) *HttpRequest {
+	req.apiKeys = append(req.apiKeys, name)
+	return req.Head(name, value)
+}
+
+// APIKeyQuery sends the key as the query parameter name.
+func (req *HttpRequest) APIKeyQuery(name, value string) *HttpRequest {
+	return req.Query(name, value)
+}
+
+// DigestAuth answers HTTP Digest challenges with username and password.
+func (req *HttpRequest) DigestAuth(username, password string) *HttpRequest {
+	req.digest = NewDigest(username, password)
+	return req
+}
+
+// UseDigest answers HTTP Digest challenges with d, sharing d between requests
+// lets them reuse the server nonce instead of being challenged every time.
+func (req *HttpRequest) UseDigest(d *Digest) *HttpRequest {
+	req.digest = d
+	return req
+}
+
+func (builder *Builder) applyAuth(req *HttpRequest) {
+	if builder.BasicAuth != nil {
+		req.BasicAuth(builder.BasicAuth.Username, builder.BasicAuth.Password)
+	}
+	if builder.BearerToken != "" {
+		req.BearerToken(builder.BearerToken)
+	}
+	if builder.APIKey != nil {
+		if builder.APIKey.InQuery {
+			req.APIKeyQuery(builder.APIKey.Name, builder.APIKey.Value)
+		} else {
+			req.APIKey(builder.APIKey.Name, builder.APIKey.Value)
+		}
+	}
+	if builder.Digest != nil {
+		req.UseDigest(builder.Digest)
+	}
+	if builder.TokenSource != nil {
+		req.OAuth2(builder.cachedTokenSource())
+	}
+	if builder.Signer != nil {
+		req.Sign(builder.Signer)
+	}
+}
+
+// cachedTokenSource returns TokenSource wrapped once in a CachedTokenSource, shared by all requests.
+func (builder *Builder) cachedTokenSource() TokenSource {
+	builder.tokenOnce.Do(func() {
+		builder.tokenSource = builder.TokenSource
+		if _, ok := builder.TokenSource.(*CachedTokenSource); !ok {
+			builder.tokenSource = ReuseTokenSource(builder.TokenSource)
+		}
+	})
+	return builder.tokenSource
+}
+
+// Digest implements HTTP Digest access authentication (RFC 7616).
+//
+// The first request is sent without credentials, a 401 challenge is answered by
+// re-sending the buffered request. Later requests to the same origin authorize
+// preemptively with the remembered nonce and an increasing nonce count until the
+// server marks it stale.
+type Digest struct {
+	Username string
+	Password string
+
+	mu         sync.Mutex
+	challenges map[string]*digestChallenge // by origin
+}
+
+func NewDigest(username, password string) *Digest {
+	return &Digest{Username: username, Password: password}
+}
+
+type digestChallenge struct {
+	realm     string
+	nonce     string
+	opaque    string
+	algorithm string
+	qop       string
+	userhash  bool
+	nc        uint32
+}
+
+func digestOrigin(u *url.URL) string {
+	return u.Scheme + "://" + strings.ToLower(u.Host)
+}
+
+// handleChallenge records the challenge of a 401 response and reports whether the request should be sent again.
+func (d *Digest) handleChallenge(request *http.Request, response *http.Response) bool {
+	var chosen map[string]string
+	for _, value := range response.Header[http.CanonicalHeaderKey("WWW-Authenticate")] {
+		params, ok := parseDigestChallenge(value)
+		if !ok || digestHash(params["algorithm"]) == nil {
+			continue
+		}
+		// prefer SHA-256 over MD5 when the server offers both
+		if chosen == nil || strings.HasPrefix(strings.ToUpper(params["algorithm"]), "SHA-") {
+			chosen = params
+		}
+	}
+	if chosen == nil {
+		return false
+	}
+	origin := digestOrigin(request.URL)
+	d.mu.Lock()
+	previous := d.challenges[origin]
+	d.mu.Unlock()
+	if request.Header.Get("Authorization") != "" && previous != nil && previous.nonce == chosen["nonce"] &&
+		!strings.EqualFold(chosen["stale"], "true") {
+		// credentials were rejected, another round would fail again
+		return false
+	}
+	c := &digestChallenge{
+		realm:     chosen["realm"],
+		nonce:     chosen["nonce"],
+		opaque:    chosen["opaque"],
+		algorithm: chosen["algorithm"],
+		userhash:  strings.EqualFold(chosen["userhash"], "true"),
+	}
+	if qop, ok := chosen["qop"]; ok {
+		for _, option := range strings.Split(qop, ",") {
+			option = strings.TrimSpace(option)
+			if option == "auth" || option == "auth-int" && c.qop == "" {
+				c.qop = option
+			}
+		}
+		if c.qop == "" {
+			return false
+		}
+	}
+	d.mu.Lock()
+	if d.challenges == nil {
+		d.challenges = make(map[string]*digestChallenge)
+	}
+	d.challenges[origin] = c
+	d.mu.Unlock()
+	return true
+}
+
+// authorize sets the Authorization header if a challenge has been received from the origin of the request.
+func (d *Digest) authorize(request *http.Request, body []byte) {
+	d.mu.Lock()
+	c := d.challenges[digestOrigin(request.URL)]
+	if c == nil {
+		d.mu.Unlock()
+		return
+	}
+	c.nc++
+	nc := fmt.Sprintf("%08x", c.nc)
+	d.mu.Unlock()
+
+	newHash := digestHash(c.algorithm)
+	h := func(s string) string {
+		hash := newHash()
+		hash.Write([]byte(s))
+		return hex.EncodeToString(hash.Sum(nil))
+	}
+	cnonce := newCnonce()
+	ha1 := h(d.Username + ":" + c.realm + ":" + d.Password)
+	if strings.HasSuffix(strings.ToLower(c.algorithm), "-sess") {
+		ha1 = h(ha1 + ":" + c.nonce + ":" + cnonce)
+	}
+	uri := request.URL.RequestURI()
+	ha2 := h(request.Method + ":" + uri)
+	if c.qop == "auth-int" {
+		ha2 = h(request.Method + ":" + uri + ":" + h(string(body)))
+	}
+	var response string
+	if c.qop == "" {
+		response = h(ha1 + ":" + c.nonce + ":" + ha2)
+	} else {
+		response = h(strings.Join([]string{ha1, c.nonce, nc, cnonce, c.qop, ha2}, ":"))
+	}
+
+	username := d.Username
+	if c.userhash {
+		username = h(d.Username + ":" + c.realm)
+	}
+	fields := []string{
+		fmt.Sprintf(`username=%q`, username),
+		fmt.Sprintf(`realm=%q`, c.realm),
+		fmt.Sprintf(`nonce=%q`, c.nonce),
+		fmt.Sprintf(`uri=%q`, uri),
+		fmt.Sprintf(`response=%q`, response),
+	}
+	if c.algorithm != "" {
+		fields = append(fields, "algorithm="+c.algorithm)
+	}
+	if c.opaque != "" {
+		fields = append(fields, fmt.Sprintf(`opaque=%q`, c.opaque))
+	}
+	if c.qop != "" {
+		fields = append(fields, "qop="+c.qop, "nc="+nc, fmt.Sprintf(`cnonce=%q`, cnonce))
+	}
+	if c.userhash {
+		fields = append(fields, "userhash=true")
+	}
+	request.Header.Set("Authorization", "Digest "+strings.Join(fields, ", "))
+}
+
+func digestHash(algorithm string) func() hash.Hash {
+	switch strings.TrimSuffix(strings.ToUpper(algorithm), "-SESS") {
+	case "", "MD5":
+		return md5.New
+	case "SHA-256":
+		return sha256.New
+	case "SHA-512-256":
+		return sha512.New512_256
+	}
+	return nil
+}
+
+func newCnonce() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// parseDigestChallenge parses `Digest realm="x", qop="auth,auth-int", nonce="..."`.
+func parseDigestChallenge(header string) (map[string]string, bool) {
+	header = strings.TrimSpace(header)
+	if len(header) < 7 || !strings.EqualFold(header[:7], "digest ") {
+		return nil, false
+	}
+	params := map[string]string{}
+	s := header[7:]
+	for {
+		s = strings.TrimLeft(s, " \t,")
+		if s == "" {
+			break
+		}
+		eq := strings.IndexByte(s, '=')
+		if eq < 0 {
+			break
+		}
+		key := strings.ToLower(strings.TrimSpace(s[:eq]))
+		s = strings.TrimLeft(s[eq+1:], " \t")
+		var value string
+		if strings.HasPrefix(s, `"`) {
+			var b strings.Builder
+			i := 1
+			for ; i < len(s) && s[i] != '"'; i++ {
+				if s[i] == '\\' && i+1 < len(s) {
+					i++
+				}
+				b.WriteByte(s[i])
+			}
+			value = b.String()
+			if i < len(s) {
+				i++
+			}
+			s = s[i:]
+		} else {
+			end := strings.IndexByte(s, ',')
+			if end < 0 {
+				end = len(s)
+			}
+			value = strings.TrimSpace(s[:end])
+			s = s[end:]
+		}
+		params[key] = value
+	}
+	return params, params["nonce"] != ""
+}
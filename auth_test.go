@@ -0,0 +1,42 @@
+package httpclient
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestDigestByOrigin(t *testing.T) {
+	var mu sync.Mutex
+	var sent []string // nonce each request authorized with, "" if none
+	server := func(nonce string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			params, _ := parseDigestChallenge(r.Header.Get("Authorization"))
+			mu.Lock()
+			sent = append(sent, params["nonce"])
+			mu.Unlock()
+			if params["nonce"] != nonce {
+				w.Header().Set("WWW-Authenticate", `Digest realm="test", qop="auth", nonce="`+nonce+`"`)
+				w.WriteHeader(http.StatusUnauthorized)
+			}
+		}))
+	}
+	a, b := server("a"), server("b")
+	defer a.Close()
+	defer b.Close()
+
+	d := NewDigest("user", "password")
+	cl := New(&http.Client{})
+	for _, url := range []string{a.URL, a.URL, b.URL, a.URL} {
+		if err := cl.Get(url).UseDigest(d).Send().CheckStatus(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// a is challenged once then authorized preemptively, b is challenged without the nonce of a
+	want := []string{"", "a", "a", "", "b", "a"}
+	if !reflect.DeepEqual(sent, want) {
+		t.Errorf("nonces sent %q, want %q", sent, want)
+	}
+}
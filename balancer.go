@@ -0,0 +1,406 @@
+package httpclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"hash/fnv"
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	defaultMaxFailures  = 5
+	defaultEjectionTime = 30 * time.Second
+	defaultReloadPeriod = 5 * time.Second
+)
+
+type BalancePolicy int
+
+const (
+	RoundRobin BalancePolicy = iota
+	LeastInFlight
+	Weighted
+	ConsistentHash // by the session id of the request, round-robin for requests without one
+)
+
+// Endpoint is a replica serving a logical host.
+type Endpoint struct {
+	Address string `json:"address"` // host:port dialed instead of the logical host
+	Weight  int    `json:"weight"`  // 1 if zero
+}
+
+func (e Endpoint) weight() int {
+	if e.Weight > 0 {
+		return e.Weight
+	}
+	return 1
+}
+
+// EndpointSource resolves a logical host to its endpoints, hosts without endpoints are not balanced.
+type EndpointSource interface {
+	Endpoints(host string) ([]Endpoint, error)
+}
+
+// StaticEndpoints maps logical hosts to a fixed set of endpoints.
+type StaticEndpoints map[string][]Endpoint
+
+func (s StaticEndpoints) Endpoints(host string) ([]Endpoint, error) {
+	return s[host], nil
+}
+
+// FileEndpoints reads the endpoints from a JSON file in the form of StaticEndpoints,
+//
+//	{"users.internal:8080": [{"address": "10.0.0.1:8080", "weight": 2}, {"address": "10.0.0.2:8080"}]}
+//
+// and reloads it when it has been modified. A file that fails to load keeps the previous endpoints.
+type FileEndpoints struct {
+	Path   string
+	Period time.Duration // how often the file is checked, 5s if zero
+
+	mu        sync.Mutex
+	endpoints StaticEndpoints
+	modTime   time.Time
+	checked   time.Time
+}
+
+func (s *FileEndpoints) Endpoints(host string) ([]Endpoint, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	period := s.Period
+	if period <= 0 {
+		period = defaultReloadPeriod
+	}
+	if s.endpoints == nil || time.Since(s.checked) >= period {
+		if err := s.reload(); err != nil && s.endpoints == nil {
+			return nil, err
+		}
+	}
+	return s.endpoints[host], nil
+}
+
+func (s *FileEndpoints) reload() error {
+	s.checked = time.Now()
+	info, err := os.Stat(s.Path)
+	if err != nil {
+		return err
+	}
+	if s.endpoints != nil && info.ModTime().Equal(s.modTime) {
+		return nil
+	}
+	data, err := ioutil.ReadFile(s.Path)
+	if err != nil {
+		return err
+	}
+	var endpoints StaticEndpoints
+	if err := json.Unmarshal(data, &endpoints); err != nil {
+		return err
+	}
+	if endpoints == nil {
+		endpoints = StaticEndpoints{}
+	}
+	s.endpoints = endpoints
+	s.modTime = info.ModTime()
+	return nil
+}
+
+// Balancer spreads the requests to a logical host over its endpoints.
+//
+// The endpoint is chosen for every request, which is sent over a pool of connections to that
+// endpoint only, so the URL, Host header and TLS server name still refer to the logical host.
+// An endpoint failing MaxFailures times in a row gets no request for EjectionTime,
+// if every endpoint is ejected all of them are used again.
+type Balancer struct {
+	Source       EndpointSource
+	Policy       BalancePolicy
+	MaxFailures  int           // 5 if zero
+	EjectionTime time.Duration // 30s if zero
+
+	mu        sync.Mutex
+	next      map[string]uint64
+	endpoints map[string]*endpointState
+}
+
+type endpointState struct {
+	inFlight     int
+	failures     int
+	ejectedUntil time.Time
+}
+
+type sessionIDKey struct{}
+
+// endpointKey holds the balancedDial of the connections of an endpoint pool.
+type endpointKey struct{}
+
+type balancedDial struct {
+	address  string // of the logical host
+	endpoint string
+}
+
+// Transport returns a RoundTripper balancing the requests sent with next over the endpoints.
+// A nil next or an *http.Transport is copied into a pool for every endpoint, other RoundTrippers
+// must dial with DialContext themselves and their connections go to any endpoint.
+func (b *Balancer) Transport(next http.RoundTripper) http.RoundTripper {
+	var base *http.Transport
+	switch t := next.(type) {
+	case nil:
+		base = b.dialing(http.DefaultTransport.(*http.Transport).Clone())
+	case *http.Transport:
+		base = b.dialing(t.Clone())
+	default:
+		return &balancedTransport{balancer: b, next: next}
+	}
+	return &balancedTransport{balancer: b, next: base, newPool: func(address, endpoint string) http.RoundTripper {
+		return dialingEndpoint(base.Clone(), address, endpoint)
+	}}
+}
+
+// dialing sets t to dial with DialContext.
+func (b *Balancer) dialing(t *http.Transport) *http.Transport {
+	t.DialContext = b.DialContext(transportDial(t))
+	return t
+}
+
+// dialingEndpoint sets t to dial endpoint instead of the logical host at address,
+// through a dial function composed with DialContext.
+func dialingEndpoint(t *http.Transport, address, endpoint string) *http.Transport {
+	dial := transportDial(t)
+	t.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return dial(context.WithValue(ctx, endpointKey{}, balancedDial{address: address, endpoint: endpoint}), network, addr)
+	}
+	return t
+}
+
+// DialContext returns a DialFunc dialing an endpoint of the host with dial instead of the host.
+// The endpoints of host:port are used, or those of host for the ports 80 and 443.
+// The connections of the pool of an endpoint dial it, the others choose an endpoint when they are dialed.
+func (b *Balancer) DialContext(dial DialFunc) DialFunc {
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		if balanced, ok := ctx.Value(endpointKey{}).(balancedDial); ok {
+			if balanced.address == address {
+				address = balanced.endpoint
+			}
+			return dial(ctx, network, address)
+		}
+		host, endpoints, err := b.endpointsOf(address)
+		if err != nil {
+			return nil, err
+		}
+		if len(endpoints) == 0 {
+			return dial(ctx, network, address)
+		}
+		sessionID, _ := ctx.Value(sessionIDKey{}).(string)
+		endpoint, state := b.pick(sessionID, host, endpoints)
+		conn, err := dial(ctx, network, endpoint.Address)
+		if err != nil {
+			b.mu.Lock()
+			b.record(state, err, nil)
+			b.mu.Unlock()
+		}
+		return conn, err
+	}
+}
+
+func (b *Balancer) endpointsOf(address string) (string, []Endpoint, error) {
+	endpoints, err := b.Source.Endpoints(address)
+	if err != nil || len(endpoints) > 0 {
+		return address, endpoints, err
+	}
+	host, port, err := net.SplitHostPort(address)
+	if err != nil || port != "80" && port != "443" {
+		return address, nil, nil
+	}
+	endpoints, err = b.Source.Endpoints(host)
+	return host, endpoints, err
+}
+
+// balancedTransport sends every request over the pool of the endpoint chosen for it.
+type balancedTransport struct {
+	balancer *Balancer
+	next     http.RoundTripper
+	// newPool returns a RoundTripper whose connections to address go to endpoint,
+	// if nil the requests are sent with next and the endpoints are chosen by DialContext.
+	newPool func(address, endpoint string) http.RoundTripper
+
+	mu    sync.Mutex
+	pools map[string]http.RoundTripper // by logical address and endpoint
+}
+
+func (t *balancedTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	if t.newPool == nil {
+		return t.next.RoundTrip(request)
+	}
+	b := t.balancer
+	address := request.URL.Host
+	if request.URL.Port() == "" {
+		port := "80"
+		if request.URL.Scheme == "https" {
+			port = "443"
+		}
+		address = net.JoinHostPort(request.URL.Hostname(), port)
+	}
+	host, endpoints, err := b.endpointsOf(address)
+	if err != nil {
+		return nil, err
+	}
+	if len(endpoints) == 0 {
+		return t.next.RoundTrip(request)
+	}
+	sessionID, _ := request.Context().Value(sessionIDKey{}).(string)
+	endpoint, state := b.pick(sessionID, host, endpoints)
+	b.mu.Lock()
+	state.inFlight++
+	b.mu.Unlock()
+	response, err := t.pool(address, endpoint.Address).RoundTrip(request)
+	b.mu.Lock()
+	state.inFlight--
+	b.record(state, err, response)
+	b.mu.Unlock()
+	return response, err
+}
+
+// pool returns the RoundTripper of the connections to address going to endpoint.
+func (t *balancedTransport) pool(address, endpoint string) http.RoundTripper {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	key := address + "\x00" + endpoint
+	pool, ok := t.pools[key]
+	if !ok {
+		if t.pools == nil {
+			t.pools = make(map[string]http.RoundTripper)
+		}
+		pool = t.newPool(address, endpoint)
+		t.pools[key] = pool
+	}
+	return pool
+}
+
+// closeIdlePools closes the idle connections of the endpoint pools.
+func (t *balancedTransport) closeIdlePools() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, pool := range t.pools {
+		closeIdle(pool)
+	}
+}
+
+func (t *balancedTransport) CloseIdleConnections() {
+	t.closeIdlePools()
+	closeIdle(t.next)
+}
+
+func (b *Balancer) pick(sessionID string, host string, endpoints []Endpoint) (Endpoint, *endpointState) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.endpoints == nil {
+		b.endpoints = make(map[string]*endpointState)
+		b.next = make(map[string]uint64)
+	}
+	now := time.Now()
+	healthy := make([]int, 0, len(endpoints))
+	for i, endpoint := range endpoints {
+		if b.state(endpoint).ejectedUntil.Before(now) {
+			healthy = append(healthy, i)
+		}
+	}
+	if len(healthy) == 0 {
+		for i := range endpoints {
+			healthy = append(healthy, i)
+		}
+	}
+
+	var chosen int
+	switch {
+	case b.Policy == ConsistentHash && sessionID != "":
+		chosen = rendezvous(sessionID, endpoints, healthy)
+	case b.Policy == Weighted:
+		chosen = weightedChoice(endpoints, healthy)
+	case b.Policy == LeastInFlight:
+		start := int(b.next[host] % uint64(len(healthy)))
+		b.next[host]++
+		chosen = healthy[start]
+		for i := 1; i < len(healthy); i++ {
+			candidate := healthy[(start+i)%len(healthy)]
+			if b.state(endpoints[candidate]).inFlight < b.state(endpoints[chosen]).inFlight {
+				chosen = candidate
+			}
+		}
+	default:
+		chosen = healthy[b.next[host]%uint64(len(healthy))]
+		b.next[host]++
+	}
+	return endpoints[chosen], b.state(endpoints[chosen])
+}
+
+// state returns the state of endpoint, b.mu must be held.
+func (b *Balancer) state(endpoint Endpoint) *endpointState {
+	state, ok := b.endpoints[endpoint.Address]
+	if !ok {
+		state = &endpointState{}
+		b.endpoints[endpoint.Address] = state
+	}
+	return state
+}
+
+// record counts the result of a request or a dial to the endpoint of state, b.mu must be held.
+func (b *Balancer) record(state *endpointState, err error, response *http.Response) {
+	if err != nil && errors.Is(err, context.Canceled) {
+		return
+	}
+	if err == nil && response.StatusCode < http.StatusInternalServerError {
+		state.failures = 0
+		return
+	}
+	state.failures++
+	maxFailures := b.MaxFailures
+	if maxFailures <= 0 {
+		maxFailures = defaultMaxFailures
+	}
+	if state.failures >= maxFailures {
+		ejectionTime := b.EjectionTime
+		if ejectionTime <= 0 {
+			ejectionTime = defaultEjectionTime
+		}
+		state.ejectedUntil = time.Now().Add(ejectionTime)
+		state.failures = 0
+	}
+}
+
+func weightedChoice(endpoints []Endpoint, candidates []int) int {
+	total := 0
+	for _, i := range candidates {
+		total += endpoints[i].weight()
+	}
+	n := rand.Intn(total)
+	for _, i := range candidates {
+		if n -= endpoints[i].weight(); n < 0 {
+			return i
+		}
+	}
+	return candidates[len(candidates)-1]
+}
+
+// rendezvous picks the candidate with the highest weighted hash of key, so a key keeps its
+// endpoint as long as it is healthy and only the keys of a removed endpoint move.
+func rendezvous(key string, endpoints []Endpoint, candidates []int) int {
+	chosen, best := candidates[0], math.Inf(-1)
+	for _, i := range candidates {
+		h := fnv.New64a()
+		h.Write([]byte(key))
+		h.Write([]byte{0})
+		h.Write([]byte(endpoints[i].Address))
+		// map the hash into (0, 1) and weight it as in weighted rendezvous hashing
+		u := (float64(h.Sum64()>>11) + 0.5) / (1 << 53)
+		score := -float64(endpoints[i].weight()) / math.Log(u)
+		if score > best {
+			chosen, best = i, score
+		}
+	}
+	return chosen
+}
@@ -0,0 +1,96 @@
+package httpclient
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/cocotyty/httpclient/cache"
+)
+
+// endpointServers starts n servers recording the X-Session header of the requests they receive.
+func endpointServers(n int, status func(i int) int) ([]*httptest.Server, StaticEndpoints, func(i int) []string) {
+	var mu sync.Mutex
+	sessions := make([][]string, n)
+	var servers []*httptest.Server
+	var endpoints []Endpoint
+	for i := 0; i < n; i++ {
+		i := i
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			sessions[i] = append(sessions[i], r.Header.Get("X-Session"))
+			mu.Unlock()
+			w.WriteHeader(status(i))
+		}))
+		servers = append(servers, server)
+		endpoints = append(endpoints, Endpoint{Address: strings.TrimPrefix(server.URL, "http://")})
+	}
+	received := func(i int) []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), sessions[i]...)
+	}
+	return servers, StaticEndpoints{"api.test": endpoints}, received
+}
+
+func TestBalancerSessionAffinity(t *testing.T) {
+	servers, endpoints, received := endpointServers(3, func(int) int { return http.StatusOK })
+	for _, server := range servers {
+		defer server.Close()
+	}
+	builder := &Builder{Cache: &cache.Cache{}, Balancer: &Balancer{Source: endpoints, Policy: ConsistentHash}}
+	for round := 0; round < 3; round++ {
+		for session := 0; session < 10; session++ {
+			id := fmt.Sprint("session", session)
+			if err := builder.Get(id).Url("http://api.test/").Head("X-Session", id).Send().CheckStatus(); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	// every request of a session goes to the endpoint of its hash, whatever connection is idle
+	count := 0
+	for i := range servers {
+		for _, session := range received(i) {
+			if want := rendezvous(session, endpoints["api.test"], []int{0, 1, 2}); i != want {
+				t.Errorf("%s sent to the endpoint %d, want %d", session, i, want)
+			}
+			count++
+		}
+	}
+	if count != 30 {
+		t.Errorf("%d requests received, want 30", count)
+	}
+}
+
+func TestBalancerEjection(t *testing.T) {
+	for _, name := range []string{"Builder", "client"} {
+		servers, endpoints, received := endpointServers(2, func(i int) int {
+			if i == 0 {
+				return http.StatusServiceUnavailable
+			}
+			return http.StatusOK
+		})
+		balancer := &Balancer{Source: endpoints, MaxFailures: 2}
+		get := New(&http.Client{}).Balancer(balancer).Get
+		if name == "Builder" {
+			builder := &Builder{Cache: &cache.Cache{}, Balancer: balancer}
+			get = func(url string) *HttpRequest { return builder.Get("").Url(url) }
+		}
+		for i := 0; i < 10; i++ {
+			get("http://api.test/").Send()
+		}
+		for _, server := range servers {
+			server.Close()
+		}
+		// round-robin until the second failure ejects the first endpoint
+		if got, want := len(received(0)), 2; got != want {
+			t.Errorf("%s: the ejected endpoint received %d requests, want %d", name, got, want)
+		}
+		if got, want := len(received(1)), 8; got != want {
+			t.Errorf("%s: the healthy endpoint received %d requests, want %d", name, got, want)
+		}
+	}
+}
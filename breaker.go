@@ -0,0 +1,234 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	defaultFailureThreshold = 5
+	defaultOpenTimeout      = 30 * time.Second
+)
+
+type BreakerState int
+
+const (
+	StateClosed BreakerState = iota
+	StateOpen
+	StateHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	}
+	return fmt.Sprintf("BreakerState(%d)", int(s))
+}
+
+// CircuitOpenError is returned without sending the request while the circuit of Key is open.
+type CircuitOpenError struct {
+	Key        string
+	RetryAfter time.Duration
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("httpclient: circuit of %s is open, retry after %s", e.Key, e.RetryAfter)
+}
+
+// CircuitBreaker fails fast for upstreams that keep failing, with a circuit per key.
+//
+// A closed circuit opens after FailureThreshold consecutive failures. An open circuit
+// rejects requests with *CircuitOpenError until OpenTimeout has passed, then turns
+// half-open and lets HalfOpenRequests trial requests through: a failed trial opens it
+// again, all trials succeeding close it.
+type CircuitBreaker struct {
+	FailureThreshold int           // 5 if zero
+	OpenTimeout      time.Duration // 30s if zero
+	HalfOpenRequests int           // 1 if zero
+
+	// KeyFunc returns the circuit of a request, the host of the URL if nil.
+	KeyFunc func(request *http.Request) string
+	// FailureStatusCodes are the status codes counted as failures, 5xx if nil.
+	FailureStatusCodes []int
+	// IsFailure overrides the classification by error and status code.
+	// By default any error but a canceled context and the FailureStatusCodes are failures.
+	IsFailure func(response *http.Response, err error) bool
+	// OnStateChange is called after the circuit of key changed its state.
+	OnStateChange func(key string, from, to BreakerState)
+
+	mu       sync.Mutex
+	circuits map[string]*circuit
+}
+
+type circuit struct {
+	state     BreakerState
+	failures  int
+	openedAt  time.Time
+	trials    int
+	successes int
+	// generation changes with the state, results of requests allowed in an earlier one are ignored
+	generation uint64
+}
+
+func (req *HttpRequest) CircuitBreaker(b *CircuitBreaker) *HttpRequest {
+	req.breaker = b
+	return req
+}
+
+// State returns the state of the circuit of key.
+func (b *CircuitBreaker) State(key string) BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if c, ok := b.circuits[key]; ok {
+		return c.state
+	}
+	return StateClosed
+}
+
+func (b *CircuitBreaker) key(request *http.Request) string {
+	if b.KeyFunc != nil {
+		return b.KeyFunc(request)
+	}
+	return request.URL.Host
+}
+
+func (b *CircuitBreaker) isFailure(response *http.Response, err error) bool {
+	if b.IsFailure != nil {
+		return b.IsFailure(response, err)
+	}
+	if err != nil {
+		return !errors.Is(err, context.Canceled)
+	}
+	if b.FailureStatusCodes == nil {
+		return response.StatusCode >= 500
+	}
+	for _, code := range b.FailureStatusCodes {
+		if response.StatusCode == code {
+			return true
+		}
+	}
+	return false
+}
+
+// allow reports whether a request to key may be sent and returns the generation of the circuit
+// it is sent in, every allowed request must be followed by done.
+func (b *CircuitBreaker) allow(key string) (uint64, error) {
+	b.mu.Lock()
+	if b.circuits == nil {
+		b.circuits = make(map[string]*circuit)
+	}
+	c, ok := b.circuits[key]
+	if !ok {
+		c = &circuit{}
+		b.circuits[key] = c
+	}
+	var changed func()
+	defer func() {
+		b.mu.Unlock()
+		if changed != nil {
+			changed()
+		}
+	}()
+	switch c.state {
+	case StateOpen:
+		wait := b.openTimeout() - time.Since(c.openedAt)
+		if wait > 0 {
+			return 0, &CircuitOpenError{Key: key, RetryAfter: wait}
+		}
+		changed = b.setState(key, c, StateHalfOpen)
+		fallthrough
+	case StateHalfOpen:
+		if c.trials >= b.halfOpenRequests() {
+			return 0, &CircuitOpenError{Key: key}
+		}
+		c.trials++
+	}
+	return c.generation, nil
+}
+
+// done records the result of a request allowed in generation. Requests canceled by the caller
+// are neither failures nor successes, a canceled trial lets another request through.
+func (b *CircuitBreaker) done(key string, generation uint64, response *http.Response, err error) {
+	failed := b.isFailure(response, err)
+	canceled := !failed && err != nil && errors.Is(err, context.Canceled)
+	b.mu.Lock()
+	c := b.circuits[key]
+	if c.generation != generation {
+		b.mu.Unlock()
+		return
+	}
+	var changed func()
+	switch {
+	case canceled:
+		if c.state == StateHalfOpen {
+			c.trials--
+		}
+	case c.state == StateClosed:
+		if !failed {
+			c.failures = 0
+			break
+		}
+		c.failures++
+		if c.failures >= b.failureThreshold() {
+			changed = b.setState(key, c, StateOpen)
+		}
+	case c.state == StateHalfOpen:
+		if failed {
+			changed = b.setState(key, c, StateOpen)
+			break
+		}
+		c.successes++
+		if c.successes >= b.halfOpenRequests() {
+			changed = b.setState(key, c, StateClosed)
+		}
+	}
+	b.mu.Unlock()
+	if changed != nil {
+		changed()
+	}
+}
+
+// setState moves c to state and returns the callback to run once b.mu is released.
+func (b *CircuitBreaker) setState(key string, c *circuit, state BreakerState) func() {
+	from := c.state
+	c.state = state
+	c.generation++
+	c.failures, c.trials, c.successes = 0, 0, 0
+	if state == StateOpen {
+		c.openedAt = time.Now()
+	}
+	if b.OnStateChange == nil {
+		return nil
+	}
+	return func() { b.OnStateChange(key, from, state) }
+}
+
+func (b *CircuitBreaker) failureThreshold() int {
+	if b.FailureThreshold > 0 {
+		return b.FailureThreshold
+	}
+	return defaultFailureThreshold
+}
+
+func (b *CircuitBreaker) openTimeout() time.Duration {
+	if b.OpenTimeout > 0 {
+		return b.OpenTimeout
+	}
+	return defaultOpenTimeout
+}
+
+func (b *CircuitBreaker) halfOpenRequests() int {
+	if b.HalfOpenRequests > 0 {
+		return b.HalfOpenRequests
+	}
+	return 1
+}
@@ -0,0 +1,149 @@
+package httpclient
+
+import (
+	"bytes"
+	"mime"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/saintfish/chardet"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/encoding/unicode"
+)
+
+// how far into the body declarations are looked for, as the HTML prescan does
+const charsetPrescanSize = 1024
+
+var (
+	xmlEncodingPattern = regexp.MustCompile(`^\s*<\?xml[^>]*\sencoding\s*=\s*["']([\w.:-]+)["']`)
+	metaCharsetPattern = regexp.MustCompile(`(?i)<meta[^>]+charset\s*=\s*["']?([\w.:-]+)`)
+)
+
+// AutoDecode detects the charset of the body and keeps it for String and HTML, which
+// otherwise detect it once on their own, and for Charset. The first match wins:
+//
+//	a byte order mark
+//	the charset of the Content-Type header
+//	the encoding of an XML declaration
+//	a <meta> charset in the first 1024 bytes
+//	statistical detection
+//
+// An encoding set with Encoding is kept. JSON is read as UTF-8 unless a byte order mark or
+// the Content-Type header declares another charset, it is never guessed.
+func (resp *HttpResponse) AutoDecode() *HttpResponse {
+	if resp.err != nil || resp.encoding != nil {
+		return resp
+	}
+	resp.encoding, resp.charset, resp.confidence = detectCharset(resp.body, resp.header.Get("Content-Type"))
+	return resp
+}
+
+// Charset returns the charset found by AutoDecode and how confident the detection is, from 0 to 1.
+// Declared charsets have a confidence of 1.
+func (resp *HttpResponse) Charset() (name string, confidence float64) {
+	return resp.charset, resp.confidence
+}
+
+// bodyEncoding returns the encoding set on the response, or the one detected as AutoDecode does,
+// once per response.
+func (resp *HttpResponse) bodyEncoding() encoding.Encoding {
+	if resp.encoding != nil {
+		return resp.encoding
+	}
+	resp.detection.Do(func() {
+		resp.detected, _, _ = detectCharset(resp.body, resp.header.Get("Content-Type"))
+	})
+	return resp.detected
+}
+
+// jsonEncoding returns the encoding of a JSON body: the one set with Encoding or declared by a byte
+// order mark or the Content-Type header, else UTF-8 as RFC 8259 requires. Nothing is guessed.
+func (resp *HttpResponse) jsonEncoding() encoding.Encoding {
+	// a charset detected statistically by AutoDecode is not trusted
+	if resp.encoding != nil && (resp.charset == "" || resp.confidence == 1) {
+		return resp.encoding
+	}
+	enc, _, _ := declaredCharset(resp.body, resp.header.Get("Content-Type"), false)
+	return enc
+}
+
+// decodedBody returns the body decoded with bodyEncoding.
+func (resp *HttpResponse) decodedBody() ([]byte, error) {
+	return resp.decode(resp.bodyEncoding())
+}
+
+func (resp *HttpResponse) decode(enc encoding.Encoding) ([]byte, error) {
+	if enc == nil || enc == unicode.UTF8 {
+		return resp.body, nil
+	}
+	return enc.NewDecoder().Bytes(resp.body)
+}
+
+func detectCharset(body []byte, contentType string) (encoding.Encoding, string, float64) {
+	if enc, name, ok := declaredCharset(body, contentType, true); ok {
+		return enc, name, 1
+	}
+	if utf8.Valid(body) {
+		// plain ASCII included, other charsets rarely form valid UTF-8 by chance
+		return unicode.UTF8, "utf-8", 1
+	}
+	result, err := chardet.NewTextDetector().DetectBest(body)
+	if err != nil {
+		return nil, "", 0
+	}
+	if enc, name, ok := lookupCharset(result.Charset); ok {
+		return enc, name, float64(result.Confidence) / 100
+	}
+	return nil, "", 0
+}
+
+// declaredCharset returns the charset declared by a byte order mark, the Content-Type header
+// or, in markup, an XML declaration or a <meta> charset in the first 1024 bytes.
+func declaredCharset(body []byte, contentType string, markup bool) (encoding.Encoding, string, bool) {
+	switch {
+	case bytes.HasPrefix(body, []byte{0xEF, 0xBB, 0xBF}):
+		return unicode.UTF8BOM, "utf-8", true
+	case bytes.HasPrefix(body, []byte{0xFF, 0xFE}):
+		return unicode.UTF16(unicode.LittleEndian, unicode.ExpectBOM), "utf-16le", true
+	case bytes.HasPrefix(body, []byte{0xFE, 0xFF}):
+		return unicode.UTF16(unicode.BigEndian, unicode.ExpectBOM), "utf-16be", true
+	}
+	if _, params, err := mime.ParseMediaType(contentType); err == nil {
+		if enc, name, ok := lookupCharset(params["charset"]); ok {
+			return enc, name, true
+		}
+	}
+	if !markup {
+		return nil, "", false
+	}
+	head := body
+	if len(head) > charsetPrescanSize {
+		head = head[:charsetPrescanSize]
+	}
+	for _, pattern := range []*regexp.Regexp{xmlEncodingPattern, metaCharsetPattern} {
+		if match := pattern.FindSubmatch(head); match != nil {
+			if enc, name, ok := lookupCharset(string(match[1])); ok {
+				return enc, name, true
+			}
+		}
+	}
+	return nil, "", false
+}
+
+func lookupCharset(label string) (encoding.Encoding, string, bool) {
+	label = strings.TrimSpace(label)
+	if label == "" {
+		return nil, "", false
+	}
+	enc, err := htmlindex.Get(label)
+	if err != nil {
+		return nil, "", false
+	}
+	name, err := htmlindex.Name(enc)
+	if err != nil {
+		name = strings.ToLower(label)
+	}
+	return enc, name, true
+}
@@ -0,0 +1,60 @@
+package httpclient
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+func latin1(s string) []byte {
+	data, _ := charmap.ISO8859_1.NewEncoder().Bytes([]byte(s))
+	return data
+}
+
+func TestCharsetDetection(t *testing.T) {
+	text := strings.Repeat("Le café où nous déjeunions était très fréquenté à l'été. ", 4)
+	resp := &HttpResponse{body: latin1(text), header: http.Header{"Content-Type": {"text/plain"}}}
+	for i := 0; i < 2; i++ {
+		if got, err := resp.String(); err != nil || got != text {
+			t.Fatalf("String() = %q, %v, want the text decoded from latin-1", got, err)
+		}
+	}
+	if resp.detected == nil {
+		t.Error("detected charset not kept for the next calls")
+	}
+	if name, confidence := resp.Charset(); name != "" || confidence != 0 {
+		t.Errorf("Charset() = %q, %v without AutoDecode, want nothing", name, confidence)
+	}
+	if name, confidence := resp.AutoDecode().Charset(); name == "" || confidence >= 1 {
+		t.Errorf("Charset() = %q, %v after AutoDecode, want a guess", name, confidence)
+	}
+}
+
+func TestJSONCharset(t *testing.T) {
+	text := strings.Repeat("Le café où nous déjeunions était très fréquenté à l'été. ", 4)
+	body := append(append([]byte(`{"text":"`), latin1(text)...), `"}`...)
+	for _, test := range []struct {
+		contentType string
+		autoDecode  bool
+		want        string
+	}{
+		// UTF-8 unless declared, invalid bytes are replaced
+		{"application/json", false, strings.ToValidUTF8(string(latin1(text)), "�")},
+		{"application/json", true, strings.ToValidUTF8(string(latin1(text)), "�")},
+		{"application/json; charset=iso-8859-1", false, text},
+	} {
+		resp := &HttpResponse{body: body, header: http.Header{"Content-Type": {test.contentType}}}
+		if test.autoDecode {
+			resp.AutoDecode()
+		}
+		var v struct{ Text string }
+		if err := resp.JSON(&v); err != nil {
+			t.Fatal(err)
+		}
+		if v.Text != test.want {
+			t.Errorf("%s (AutoDecode %t): JSON text %q, want %q", test.contentType, test.autoDecode, v.Text, test.want)
+		}
+	}
+}
@@ -3,7 +3,6 @@ package httpclient
 import (
 	"crypto/tls"
 	"encoding/json"
-	"net"
 	"net/http"
 	"sync"
 	"time"
@@ -32,15 +31,39 @@ func (s *HttpService) Post(sessionid string) *HttpRequest {
 }
 
 type Builder struct {
+	BaseURL            string
 	SessionCachePrefix string
 	SessionCachedTime  time.Duration
 	Timeout            time.Duration
 	Proxy              string
-	Auth               *proxy.Auth
-	Cache              Cache // Cache to store cookies
+	Auth               *proxy.Auth // Auth of the SOCKS5 proxy
+	Cache              Cache       // Cache to store cookies
 	UserAgentsPool     []string
+	BasicAuth          *BasicAuth // default credentials of every request
+	BearerToken        string
+	APIKey             *APIKey
+	Digest             *Digest
+	TokenSource        TokenSource // OAuth2 tokens, cached with ReuseTokenSource unless it is a *CachedTokenSource
+	Signer             Signer
+	CircuitBreaker     *CircuitBreaker // shared by all requests, one circuit per host
+	Balancer           *Balancer
+	Resolver           *Resolver // resolves the hosts dialed, unused with UnixSocket
+	DialContext        DialFunc  // dials the connections instead of a net.Dialer, the SOCKS5 proxy dials through it
+	UnixSocket         string    // path of the unix socket all requests are sent to, @name for an abstract socket
+	Protocol           Protocol
+	HTTP2              HTTP2Options
+	RedirectPolicy     *RedirectPolicy // not applied to NoAutoRedirectRequest
+	Pool               Pool
+	SessionProxy       func(sessionID string) string // SOCKS5 proxy of each session instead of Proxy
+	Robots             *Robots                       // rejects the requests robots.txt disallows
 	Transport          *http.Transport
+	poolMu             sync.Mutex
+	transports         map[string]*transportSet
+	swept              time.Time // when the idle PerSession pools were last closed
+	lifecycle          tracker
 	once               sync.Once
+	tokenOnce          sync.Once
+	tokenSource        TokenSource // TokenSource cached
 }
 
 func (builder *Builder) loadCache(sessionID string) (data []byte) {
@@ -72,28 +95,32 @@ func (builder *Builder) initTransport() {
 	if builder.Transport == nil {
 		builder.Transport = &http.Transport{
 			TLSClientConfig:       &tls.Config{InsecureSkipVerify: true},
-			MaxConnsPerHost:       2000,
-			MaxIdleConns:          2000,
-			IdleConnTimeout:       time.Minute,
+			MaxConnsPerHost:       defaultMaxConnsPerHost,
+			MaxIdleConns:          defaultMaxIdleConns,
+			MaxIdleConnsPerHost:   defaultMaxIdleConnsPerHost,
+			IdleConnTimeout:       defaultIdleConnTimeout,
 			ExpectContinueTimeout: 0,
 		}
 	}
-	dialer := &net.Dialer{Timeout: builder.Timeout}
-	if builder.Proxy == "" {
-		builder.Transport.DialContext = dialer.DialContext
-	} else {
-		proxyDialer, _ := proxy.SOCKS5("tcp", builder.Proxy, builder.Auth, dialer)
-		builder.Transport.DialContext = proxyDialer.(proxy.ContextDialer).DialContext
-	}
+	builder.Pool.apply(builder.Transport)
 }
 
 func (builder *Builder) newRequest(sessionID string, noAutoRedirect bool) *HttpRequest {
 	builder.once.Do(builder.initTransport)
 	jarData := builder.loadCache(sessionID)
-	return NewHttpRequest(builder.injectCookiesClient(jarData, noAutoRedirect)).
+	req := NewHttpRequest(builder.injectCookiesClient(sessionID, jarData, noAutoRedirect)).
 		SetCookieStore(builder.storeCookie).
 		SetUserAgentPool(builder.UserAgentsPool).
-		Session(sessionID)
+		BaseURL(builder.BaseURL).
+		Session(sessionID).
+		CircuitBreaker(builder.CircuitBreaker).
+		Robots(builder.Robots)
+	req.tracker = &builder.lifecycle
+	if !noAutoRedirect {
+		req.Redirects(builder.RedirectPolicy)
+	}
+	builder.applyAuth(req)
+	return req
 }
 
 func (builder *Builder) NoAutoRedirectRequest(sessionID string) *HttpRequest {
@@ -116,9 +143,9 @@ func (builder *Builder) storeCookie(sessionID string, cookieJar http.CookieJar)
 	builder.saveCache(sessionID, data)
 }
 
-func (builder *Builder) injectCookiesClient(cookieJarBytes []byte, noAutoRedirect bool) *http.Client {
+func (builder *Builder) injectCookiesClient(sessionID string, cookieJarBytes []byte, noAutoRedirect bool) *http.Client {
 	var cl = &http.Client{}
-	cl.Transport = builder.Transport
+	cl.Transport = builder.transportFor(sessionID)
 	cl.Timeout = builder.Timeout
 	cl.CheckRedirect = func(req *http.Request, via []*http.Request) error {
 		if noAutoRedirect {
@@ -1,14 +1,33 @@
 package httpclient
 
 import (
+	"context"
 	"crypto/tls"
 	"net"
 	"net/http"
+	"strings"
 	"time"
 )
 
 type client struct {
-	cl *http.Client
+	cl          *http.Client
+	baseURL     string
+	tokenSource TokenSource
+	signer      Signer
+	breaker     *CircuitBreaker
+	redirects   *RedirectPolicy
+	robots      *Robots
+
+	// the dial options, composed by configureDial
+	baseDial   DialFunc
+	dial       DialFunc
+	unixSocket string
+	resolver   *Resolver
+	balancer   *Balancer
+
+	// set up again in the endpoint pools of balancer
+	protocol Protocol
+	http2    HTTP2Options
 }
 
 // NewNoSSLVerify create a client which will skip ssl verify.
@@ -35,26 +54,223 @@ func New(cl *http.Client) *client {
 	return &client{cl: cl}
 }
 
+// BaseURL sets the prefix joined to the relative urls passed to the request methods.
+func (cl *client) BaseURL(base string) *client {
+	cl.baseURL = base
+	return cl
+}
+
+// TokenSource authorizes every request with OAuth2 tokens from ts, cached with ReuseTokenSource unless it is a *CachedTokenSource.
+func (cl *client) TokenSource(ts TokenSource) *client {
+	if _, ok := ts.(*CachedTokenSource); !ok && ts != nil {
+		ts = ReuseTokenSource(ts)
+	}
+	cl.tokenSource = ts
+	return cl
+}
+
+// Signer signs every request with s.
+func (cl *client) Signer(s Signer) *client {
+	cl.signer = s
+	return cl
+}
+
+// CircuitBreaker fails requests fast while their upstream keeps failing.
+func (cl *client) CircuitBreaker(b *CircuitBreaker) *client {
+	cl.breaker = b
+	return cl
+}
+
+// Balancer spreads the requests over the endpoints of their host, the http.Client is copied.
+func (cl *client) Balancer(b *Balancer) *client {
+	cl.balancer = b
+	cl.configureDial()
+	var balanced http.Client
+	if cl.cl != nil {
+		balanced = *cl.cl
+	}
+	next := balanced.Transport
+	if t, ok := next.(*balancedTransport); ok {
+		next = t.next
+	}
+	balanced.Transport = cl.balanced(next)
+	cl.cl = &balanced
+	return cl
+}
+
+// balanced returns next balanced over a pool per endpoint, set up as next if the client configured it.
+func (cl *client) balanced(next http.RoundTripper) *balancedTransport {
+	balanced := &balancedTransport{balancer: cl.balancer, next: next}
+	base, ok := next.(*http.Transport)
+	if h2c, isH2C := next.(*h2cTransport); isH2C {
+		base, ok = h2c.next.(*http.Transport)
+	}
+	if !ok {
+		return balanced
+	}
+	base = base.Clone()
+	protocol, options := cl.protocol, cl.http2
+	balanced.newPool = func(address, endpoint string) http.RoundTripper {
+		return configureProtocol(dialingEndpoint(base.Clone(), address, endpoint), protocol, options)
+	}
+	return balanced
+}
+
+// Redirects follows the redirects of every request by policy.
+func (cl *client) Redirects(policy *RedirectPolicy) *client {
+	cl.redirects = policy
+	return cl
+}
+
+// Robots rejects the requests disallowed by robots.txt.
+func (cl *client) Robots(r *Robots) *client {
+	cl.robots = r
+	return cl
+}
+
+// Resolver resolves the hosts dialed by the client with r, see configureDial.
+func (cl *client) Resolver(r *Resolver) *client {
+	cl.resolver = r
+	cl.configureDial()
+	return cl
+}
+
+// DialContext dials the connections of the client with dial, see configureDial.
+func (cl *client) DialContext(dial DialFunc) *client {
+	cl.dial = dial
+	cl.configureDial()
+	return cl
+}
+
+// UnixSocket sends all requests to the unix socket at path, unix:///path and @name for an
+// abstract socket are accepted. The host of the request urls is only used as Host header.
+func (cl *client) UnixSocket(path string) *client {
+	cl.unixSocket = path
+	cl.configureDial()
+	return cl
+}
+
+// configureDial sets the dial function of the transport from the dial options, composed as
+// the Builder does whatever order they are set in: the address to dial is the endpoint chosen
+// by Balancer, the unix socket of UnixSocket or else the IPs of Resolver, dialed with DialContext
+// or the dial function the transport had.
+func (cl *client) configureDial() {
+	cl.withTransport(func(t *http.Transport) {
+		if cl.baseDial == nil {
+			cl.baseDial = transportDial(t)
+		}
+		dial := cl.baseDial
+		if cl.dial != nil {
+			dial = cl.dial
+		}
+		switch {
+		case cl.unixSocket != "":
+			dial = unixSocketDial(cl.unixSocket, dial)
+		case cl.resolver != nil:
+			dial = cl.resolver.DialContext(dial)
+		}
+		if cl.balancer != nil {
+			dial = cl.balancer.DialContext(dial)
+		}
+		t.DialContext = dial
+	})
+}
+
+func unixSocketDial(path string, dial DialFunc) DialFunc {
+	path = strings.TrimPrefix(path, "unix://")
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		return dial(ctx, "unix", path)
+	}
+}
+
+// Protocol selects the HTTP version of the client, options configure the HTTP/2 health checks and may be nil.
+func (cl *client) Protocol(protocol Protocol, options *HTTP2Options) *client {
+	if options == nil {
+		options = &HTTP2Options{}
+	}
+	cl.protocol, cl.http2 = protocol, *options
+	cl.withRoundTripper(func(t *http.Transport) http.RoundTripper {
+		return configureProtocol(t, protocol, *options)
+	})
+	return cl
+}
+
+// withTransport applies configure to a copy of the transport of the client,
+// transports other than *http.Transport are left unchanged.
+func (cl *client) withTransport(configure func(t *http.Transport)) {
+	cl.withRoundTripper(func(t *http.Transport) http.RoundTripper {
+		configure(t)
+		return t
+	})
+}
+
+// withRoundTripper replaces the transport of the client with the RoundTripper built from a copy of it.
+func (cl *client) withRoundTripper(build func(t *http.Transport) http.RoundTripper) {
+	var configured http.Client
+	if cl.cl != nil {
+		configured = *cl.cl
+	}
+	configured.Transport = cl.configureTransport(configured.Transport, build)
+	if balanced, ok := configured.Transport.(*balancedTransport); ok {
+		configured.Transport = cl.balanced(balanced.next)
+	}
+	cl.cl = &configured
+}
+
+func (cl *client) configureTransport(rt http.RoundTripper, build func(t *http.Transport) http.RoundTripper) http.RoundTripper {
+	switch t := rt.(type) {
+	case nil:
+		return build(http.DefaultTransport.(*http.Transport).Clone())
+	case *http.Transport:
+		return build(t.Clone())
+	case *h2cTransport:
+		if next, ok := t.next.(*http.Transport); ok {
+			built := build(next.Clone())
+			// h2c is kept by the options other than Protocol, set up again over the transport they configured
+			if transport, ok := built.(*http.Transport); ok && cl.protocol == H2C {
+				return configureProtocol(transport, H2C, cl.http2)
+			}
+			return built
+		}
+	case *balancedTransport:
+		return &balancedTransport{balancer: t.balancer, next: cl.configureTransport(t.next, build)}
+	}
+	return rt
+}
+
+func transportDial(t *http.Transport) DialFunc {
+	if t.DialContext != nil {
+		return t.DialContext
+	}
+	return (&net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}).DialContext
+}
+
+func (cl *client) request(method, url string) *HttpRequest {
+	return &HttpRequest{header: http.Header{}, baseURL: cl.baseURL, url: url, method: method, client: cl.cl,
+		tokenSource: cl.tokenSource, signer: cl.signer, breaker: cl.breaker, redirectPolicy: cl.redirects,
+		robots: cl.robots}
+}
+
 func (cl *client) Get(url string) *HttpRequest {
-	return &HttpRequest{header: http.Header{}, url: url, method: http.MethodGet, client: cl.cl}
+	return cl.request(http.MethodGet, url)
 }
 func (cl *client) Post(url string) *HttpRequest {
-	return &HttpRequest{header: http.Header{}, url: url, method: http.MethodPost, client: cl.cl}
+	return cl.request(http.MethodPost, url)
 }
 func (cl *client) Delete(url string) *HttpRequest {
-	return &HttpRequest{header: http.Header{}, url: url, method: http.MethodDelete, client: cl.cl}
+	return cl.request(http.MethodDelete, url)
 }
 func (cl *client) Put(url string) *HttpRequest {
-	return &HttpRequest{header: http.Header{}, url: url, method: http.MethodPut, client: cl.cl}
+	return cl.request(http.MethodPut, url)
 }
 func (cl *client) Patch(url string) *HttpRequest {
-	return &HttpRequest{header: http.Header{}, url: url, method: http.MethodPatch, client: cl.cl}
+	return cl.request(http.MethodPatch, url)
 }
 func (cl *client) Head(url string) *HttpRequest {
-	return &HttpRequest{header: http.Header{}, url: url, method: http.MethodHead, client: cl.cl}
+	return cl.request(http.MethodHead, url)
 }
 func (cl *client) Options(url string) *HttpRequest {
-	return &HttpRequest{header: http.Header{}, url: url, method: http.MethodOptions, client: cl.cl}
+	return cl.request(http.MethodOptions, url)
 }
 
 type Cache interface {
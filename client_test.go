@@ -0,0 +1,102 @@
+package httpclient
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+func TestUnixSocket(t *testing.T) {
+	dir, err := ioutil.TempDir("", "httpclient")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "server.sock")
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		t.Skip("unix sockets are not supported:", err)
+	}
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Host + r.URL.Path))
+	}))
+	server.Listener = listener
+	server.Start()
+	defer server.Close()
+
+	var dialed []string
+	dial := func(ctx context.Context, network, address string) (net.Conn, error) {
+		dialed = append(dialed, network+" "+address)
+		return (&net.Dialer{}).DialContext(ctx, network, address)
+	}
+	resolver := &Resolver{Hosts: map[string][]string{"api.test": {"192.0.2.1"}}}
+	clients := map[string]*client{
+		"unix socket":               New(nil).UnixSocket(path),
+		"unix:// url":               New(nil).UnixSocket("unix://" + path),
+		"dial then unix socket":     New(nil).DialContext(dial).UnixSocket(path),
+		"unix socket then dial":     New(nil).UnixSocket(path).DialContext(dial),
+		"resolver then unix socket": New(nil).Resolver(resolver).UnixSocket(path),
+		"unix socket then resolver": New(nil).UnixSocket(path).Resolver(resolver),
+		"http.Client":               New(&http.Client{Transport: &http.Transport{DisableKeepAlives: true}}).UnixSocket(path),
+	}
+	for name, cl := range clients {
+		dialed = nil
+		body, err := cl.Get("http://api.test/users").Send().String()
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if body != "api.test/users" {
+			t.Errorf("%s: body = %q, want %q", name, body, "api.test/users")
+		}
+		if cl.dial != nil && (len(dialed) != 1 || dialed[0] != "unix "+path) {
+			t.Errorf("%s: dialed %q, want the socket through the dial function", name, dialed)
+		}
+	}
+}
+
+func TestH2CWithDialOptions(t *testing.T) {
+	server := httptest.NewServer(h2c.NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, r.ProtoMajor)
+	}), &http2.Server{}))
+	defer server.Close()
+
+	dialed := 0
+	dial := func(ctx context.Context, network, address string) (net.Conn, error) {
+		dialed++
+		return (&net.Dialer{}).DialContext(ctx, network, address)
+	}
+	options := &HTTP2Options{ReadIdleTimeout: time.Minute}
+	clients := map[string]*client{
+		"h2c then dial":     New(&http.Client{}).Protocol(H2C, options).DialContext(dial),
+		"dial then h2c":     New(&http.Client{}).DialContext(dial).Protocol(H2C, options),
+		"h2c then resolver": New(&http.Client{}).Protocol(H2C, options).Resolver(&Resolver{}).DialContext(dial),
+	}
+	for name, cl := range clients {
+		dialed = 0
+		body, err := cl.Get(server.URL).Send().String()
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if body != "2" {
+			t.Errorf("%s: request sent with HTTP/%s, want HTTP/2", name, body)
+		}
+		if dialed != 1 {
+			t.Errorf("%s: dialed %d times with the dial function, want once", name, dialed)
+		}
+		if transport, ok := cl.cl.Transport.(*h2cTransport); !ok || transport.h2c.ReadIdleTimeout != time.Minute {
+			t.Errorf("%s: transport %T without the HTTP/2 options", name, cl.cl.Transport)
+		}
+	}
+}
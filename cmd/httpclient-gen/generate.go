@@ -0,0 +1,345 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const libraryPath = "github.com/cocotyty/httpclient"
+
+type generator struct {
+	pkg  *pkgInfo
+	args []string // command line recorded in the header
+	buf  bytes.Buffer
+}
+
+func (g *generator) printf(format string, args ...interface{}) {
+	fmt.Fprintf(&g.buf, format, args...)
+}
+
+func (g *generator) header(imports map[string]string) {
+	g.printf("// Code generated by \"httpclient-gen %s\"; DO NOT EDIT.\n\n", strings.Join(g.args, " "))
+	g.printf("package %s\n\n", g.pkg.name)
+	var names []string
+	for name := range imports {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var std, thirdParty []string
+	for _, name := range names {
+		path := imports[name]
+		spec := fmt.Sprintf("%q", path)
+		if path[strings.LastIndex(path, "/")+1:] != name {
+			spec = name + " " + spec
+		}
+		if strings.Contains(strings.SplitN(path, "/", 2)[0], ".") {
+			thirdParty = append(thirdParty, spec)
+		} else {
+			std = append(std, spec)
+		}
+	}
+	g.printf("import (\n")
+	for _, spec := range std {
+		g.printf("\t%s\n", spec)
+	}
+	if len(std) > 0 && len(thirdParty) > 0 {
+		g.printf("\n")
+	}
+	for _, spec := range thirdParty {
+		g.printf("\t%s\n", spec)
+	}
+	g.printf(")\n")
+}
+
+func (g *generator) client(services []*service) []byte {
+	g.buf.Reset()
+	imports := map[string]string{"http": "net/http", "httpclient": libraryPath}
+	for _, svc := range services {
+		for name, path := range svc.imports {
+			imports[name] = path
+		}
+		for _, m := range svc.Methods {
+			if m.Context != "" {
+				imports["context"] = "context"
+			}
+			for _, call := range m.Calls {
+				if strings.Contains(call, "fmt.Sprint(") {
+					imports["fmt"] = "fmt"
+				}
+			}
+		}
+	}
+	g.header(imports)
+	for _, svc := range services {
+		g.service(svc)
+	}
+	return append([]byte(nil), g.buf.Bytes()...)
+}
+
+func (g *generator) service(svc *service) {
+	name := svc.Name + "Client"
+	g.printf("\n// %s implements %s with httpclient.\n", name, svc.Name)
+	g.printf("type %s struct {\n\tnewRequest func() *httpclient.HttpRequest\n}\n\n", name)
+	g.printf("var _ %s = (*%s)(nil)\n\n", svc.Name, name)
+	g.printf("// New%s returns a client sending requests to baseURL through cl, http.DefaultClient is used if cl is nil.\n", name)
+	g.printf("func New%s(baseURL string, cl *http.Client) *%s {\n", name, name)
+	g.printf("\tif cl == nil {\n\t\tcl = http.DefaultClient\n\t}\n")
+	g.printf("\treturn New%sWithFactory(func() *httpclient.HttpRequest {\n", name)
+	g.printf("\t\treturn httpclient.NewHttpRequest(cl).BaseURL(baseURL)\n\t})\n}\n\n")
+	g.printf("// New%sWithFactory returns a client whose requests are created by newRequest,\n", name)
+	g.printf("// e.g. func() *httpclient.HttpRequest { return builder.Request(sessionID) }.\n")
+	g.printf("func New%sWithFactory(newRequest func() *httpclient.HttpRequest) *%s {\n", name, name)
+	g.printf("\treturn &%s{newRequest: newRequest}\n}\n", name)
+
+	for _, m := range svc.Methods {
+		g.method(name, m)
+	}
+
+	g.printf("\nfunc (c *%s) check(resp *httpclient.HttpResponse) error {\n", name)
+	if svc.ErrorType == "" {
+		g.printf("\treturn resp.CheckStatus()\n}\n")
+		return
+	}
+	g.printf("\terr := resp.CheckStatus()\n")
+	g.printf("\tstatusErr, ok := err.(*httpclient.StatusError)\n")
+	g.printf("\tif !ok {\n\t\treturn err\n\t}\n")
+	g.printf("\tapiErr := &%s{}\n", svc.ErrorType)
+	g.printf("\tif statusErr.JSON(apiErr) != nil {\n\t\treturn err\n\t}\n")
+	g.printf("\treturn apiErr\n}\n")
+}
+
+func (g *generator) method(client string, m *method) {
+	var params []string
+	if m.Context != "" {
+		params = append(params, m.Context+" context.Context")
+	}
+	for _, p := range m.Params {
+		params = append(params, p.Name+" "+p.Type)
+	}
+	results := "(err error)"
+	if m.Result != "" {
+		results = fmt.Sprintf("(result %s, err error)", m.Result)
+	}
+	g.printf("\nfunc (c *%s) %s(%s) %s {\n", client, m.Name, strings.Join(params, ", "), results)
+	g.printf("\tresp := c.newRequest().\n")
+	if m.Context != "" {
+		g.printf("\t\tContext(%s).\n", m.Context)
+	}
+	g.printf("\t\tMethod(%q).\n", m.HTTPMethod)
+	g.printf("\t\tPath(%q).\n", m.Path)
+	for _, call := range m.Calls {
+		g.printf("\t\t%s.\n", call)
+	}
+	g.printf("\t\tSend()\n")
+	if m.Result == "" {
+		g.printf("\treturn c.check(resp)\n}\n")
+		return
+	}
+	g.printf("\tif err = c.check(resp); err != nil {\n\t\treturn\n\t}\n")
+	switch m.Result {
+	case "[]byte":
+		g.printf("\tresult, err = resp.Body()\n")
+	case "string":
+		g.printf("\tresult, err = resp.String()\n")
+	default:
+		g.printf("\terr = resp.JSON(&result)\n")
+	}
+	g.printf("\treturn\n}\n")
+}
+
+// sample returns a literal used for a parameter in generated tests, or "" if the zero value is used.
+func sample(typ string) string {
+	switch typ {
+	case "string":
+		return `"test"`
+	case "int", "int8", "int16", "int32", "int64", "uint", "uint8", "uint16", "uint32", "uint64", "float32", "float64":
+		return "1"
+	case "bool":
+		return "true"
+	case "[]string":
+		return `[]string{"test"}`
+	}
+	return ""
+}
+
+// sent returns the value the server receives for a sample parameter bound by annotation.
+func sent(annotation string, typ string) string {
+	switch {
+	case typ == "[]string" && (annotation == "@Query" || annotation == "@Form"):
+		return "test"
+	case typ == "[]string":
+		return "[test]"
+	}
+	return strings.Trim(sample(typ), `"`)
+}
+
+// checked returns the bindings of m the generated test can check.
+func checked(m *method) (bindings []*binding) {
+	for _, b := range m.Bindings {
+		switch {
+		case b.Annotation == "@Body":
+		case b.Param == nil:
+		case b.Key != "" && sample(b.Param.Type) != "":
+		default:
+			continue
+		}
+		bindings = append(bindings, b)
+	}
+	return
+}
+
+func (g *generator) tests(services []*service) []byte {
+	g.buf.Reset()
+	imports := map[string]string{"http": "net/http", "httptest": "net/http/httptest", "testing": "testing"}
+	for _, svc := range services {
+		for _, m := range svc.Methods {
+			if m.Context != "" {
+				imports["context"] = "context"
+			}
+			for _, b := range checked(m) {
+				switch b.Annotation {
+				case "@Query":
+					imports["url"] = "net/url"
+				case "@Form":
+					imports["url"], imports["ioutil"] = "net/url", "io/ioutil"
+				case "@Body":
+					imports["bytes"], imports["ioutil"], imports["json"] = "bytes", "io/ioutil", "encoding/json"
+				}
+			}
+			for _, p := range m.Params {
+				if sample(p.Type) != "" {
+					continue
+				}
+				for name, path := range svc.imports {
+					if strings.Contains(p.Type, name+".") {
+						imports[name] = path
+					}
+				}
+			}
+		}
+	}
+	g.header(imports)
+	for _, svc := range services {
+		for _, m := range svc.Methods {
+			g.test(svc, m)
+		}
+	}
+	return append([]byte(nil), g.buf.Bytes()...)
+}
+
+func (g *generator) test(svc *service, m *method) {
+	client := svc.Name + "Client"
+	expectedPath := m.Path
+	var args []string
+	if m.Context != "" {
+		args = append(args, "context.Background()")
+	}
+	for _, p := range m.Params {
+		args = append(args, p.Name)
+	}
+	for _, match := range pathParamPattern.FindAllStringSubmatch(m.Path, -1) {
+		for _, p := range m.Params {
+			if p.Name != match[1] {
+				continue
+			}
+			if sample(p.Type) == "" {
+				expectedPath = ""
+				break
+			}
+			expectedPath = strings.Replace(expectedPath, match[0], sent("", p.Type), 1)
+		}
+	}
+	call := fmt.Sprintf("c.%s(%s)", m.Name, strings.Join(args, ", "))
+	if m.Result != "" {
+		call = "_, err := " + call
+	} else {
+		call = "err := " + call
+	}
+
+	bindings := checked(m)
+	var query, header, body bool
+	for _, b := range bindings {
+		query = query || b.Annotation == "@Query"
+		header = header || b.Annotation == "@Header"
+		body = body || b.Annotation == "@Form" || b.Annotation == "@Body"
+	}
+	g.printf("\nfunc Test%s_%s(t *testing.T) {\n", client, m.Name)
+	g.printf("\tvar method, path string\n")
+	if query {
+		g.printf("\tvar query url.Values\n")
+	}
+	if header {
+		g.printf("\tvar header http.Header\n")
+	}
+	if body {
+		g.printf("\tvar body []byte\n")
+	}
+	g.printf("\tsrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {\n")
+	g.printf("\t\tmethod, path = r.Method, r.URL.Path\n")
+	if query {
+		g.printf("\t\tquery = r.URL.Query()\n")
+	}
+	if header {
+		g.printf("\t\theader = r.Header\n")
+	}
+	if body {
+		g.printf("\t\tbody, _ = ioutil.ReadAll(r.Body)\n")
+	}
+	g.printf("\t\tw.Header().Set(\"Content-Type\", \"application/json\")\n")
+	g.printf("\t\t_, _ = w.Write([]byte(\"null\"))\n")
+	g.printf("\t}))\n\tdefer srv.Close()\n\n")
+	g.params(m)
+	g.printf("\tc := New%s(srv.URL, srv.Client())\n", client)
+	g.printf("\tif %s; err != nil {\n\t\tt.Fatal(err)\n\t}\n", call)
+	g.printf("\tif method != %q {\n\t\tt.Errorf(\"method = %%q, want %%q\", method, %q)\n\t}\n", m.HTTPMethod, m.HTTPMethod)
+	if expectedPath != "" {
+		g.printf("\tif path != %q {\n\t\tt.Errorf(\"path = %%q, want %%q\", path, %q)\n\t}\n", expectedPath, expectedPath)
+	}
+	form := false
+	for _, b := range bindings {
+		want := b.Value
+		if b.Param != nil {
+			want = sent(b.Annotation, b.Param.Type)
+		}
+		var got, name string
+		switch b.Annotation {
+		case "@Query":
+			got, name = fmt.Sprintf("query.Get(%q)", b.Key), "query "+b.Key
+		case "@Header":
+			got, name = fmt.Sprintf("header.Get(%q)", b.Key), "header "+b.Key
+		case "@Form":
+			if !form {
+				g.printf("\tform, _ := url.ParseQuery(string(body))\n")
+				form = true
+			}
+			got, name = fmt.Sprintf("form.Get(%q)", b.Key), "form "+b.Key
+		case "@Body":
+			g.printf("\tif want, _ := json.Marshal(%s); !bytes.Equal(body, want) {\n", b.Param.Name)
+			g.printf("\t\tt.Errorf(\"body = %%s, want %%s\", body, want)\n\t}\n")
+			continue
+		}
+		g.printf("\tif got := %s; got != %q {\n\t\tt.Errorf(\"%s = %%q, want %%q\", got, %q)\n\t}\n", got, want, name, want)
+	}
+	g.printf("}\n")
+
+	g.printf("\nfunc Test%s_%sError(t *testing.T) {\n", client, m.Name)
+	g.printf("\tsrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {\n")
+	g.printf("\t\tw.WriteHeader(http.StatusInternalServerError)\n")
+	g.printf("\t}))\n\tdefer srv.Close()\n\n")
+	g.params(m)
+	g.printf("\tc := New%s(srv.URL, srv.Client())\n", client)
+	g.printf("\tif %s; err == nil {\n\t\tt.Fatal(\"expected an error for status 500\")\n\t}\n", call)
+	g.printf("}\n")
+}
+
+func (g *generator) params(m *method) {
+	for _, p := range m.Params {
+		if value := sample(p.Type); value != "" {
+			g.printf("\tvar %s %s = %s\n", p.Name, p.Type, value)
+		} else {
+			g.printf("\tvar %s %s\n", p.Name, p.Type)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+// Command httpclient-gen generates typed clients from annotated Go interfaces.
+//
+// Add a go:generate directive next to the interface:
+//
+//	//go:generate go run github.com/cocotyty/httpclient/cmd/httpclient-gen -type UserService
+//
+//	// @Error APIError
+//	type UserService interface {
+//		// @GET /users/{id}
+//		// @Query page
+//		GetUser(ctx context.Context, id string, page int) (*User, error)
+//
+//		// @POST /users
+//		// @Header Accept: application/json
+//		// @Body user
+//		CreateUser(ctx context.Context, user *User) (*User, error)
+//	}
+//
+// Method annotations:
+//
+//	@GET|@POST|@PUT|@PATCH|@DELETE|@HEAD|@OPTIONS path   request line, {name} is bound to the parameter name
+//	@Query key [param]      query value, []string parameters are repeated
+//	@Header key [param]     header value, or a constant with "@Header Key: value"
+//	@Form key [param]       form value, []string parameters are repeated
+//	@Body param             JSON body
+//	@QueryStruct param      query values from struct tags, likewise @FormStruct and @HeaderStruct
+//
+// Interface annotations:
+//
+//	@Error Type             decode error responses into *Type, which must implement error
+//
+// Results may be error, or (T, error) where T is []byte, string or decoded from JSON.
+// A leading context.Context parameter is attached to the request.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	typeNames = flag.String("type", "", "comma-separated list of interface names; must be set")
+	output    = flag.String("output", "", "output file name; default srcdir/<type>_client.go")
+	tests     = flag.Bool("tests", false, "also generate <output>_test.go exercising every method against httptest")
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage of httpclient-gen:\n")
+	fmt.Fprintf(os.Stderr, "\thttpclient-gen -type T [directory]\n")
+	flag.PrintDefaults()
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("httpclient-gen: ")
+	flag.Usage = usage
+	flag.Parse()
+	if *typeNames == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	dir := "."
+	if args := flag.Args(); len(args) > 0 {
+		dir = args[0]
+	}
+
+	pkg, err := parsePackage(dir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var services []*service
+	for _, name := range strings.Split(*typeNames, ",") {
+		svc, err := pkg.service(strings.TrimSpace(name))
+		if err != nil {
+			log.Fatal(err)
+		}
+		services = append(services, svc)
+	}
+
+	outputName := *output
+	if outputName == "" {
+		outputName = filepath.Join(dir, strings.ToLower(services[0].Name)+"_client.go")
+	}
+	g := &generator{pkg: pkg, args: os.Args[1:]}
+	write(outputName, g.client(services))
+	if *tests {
+		write(strings.TrimSuffix(outputName, ".go")+"_test.go", g.tests(services))
+	}
+}
+
+func write(name string, src []byte) {
+	formatted, err := format.Source(src)
+	if err != nil {
+		log.Printf("warning: internal error: invalid Go generated: %s", err)
+		log.Printf("warning: compile the package to analyze the error")
+		formatted = src
+	}
+	if err := ioutil.WriteFile(name, formatted, 0644); err != nil {
+		log.Fatalf("writing output: %s", err)
+	}
+}
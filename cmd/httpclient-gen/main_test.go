@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"go/format"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+func TestGolden(t *testing.T) {
+	pkg, err := parsePackage(filepath.Join("testdata", "users"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc, err := pkg.service("UserService")
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := &generator{pkg: pkg, args: []string{"-type", "UserService", "-tests"}}
+	for _, golden := range []struct {
+		name string
+		src  []byte
+	}{
+		{"userservice_client.go.golden", g.client([]*service{svc})},
+		{"userservice_client_test.go.golden", g.tests([]*service{svc})},
+	} {
+		got, err := format.Source(golden.src)
+		if err != nil {
+			t.Fatalf("%s: invalid Go generated: %v", golden.name, err)
+		}
+		name := filepath.Join("testdata", golden.name)
+		if *update {
+			if err := ioutil.WriteFile(name, got, 0644); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		want, err := ioutil.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("%s differs from the generated code, run go test -update to accept it:\n%s", name, got)
+		}
+	}
+}
+
+// TestGeneratedPackage builds the generated client with the interface it implements and runs its generated tests.
+func TestGeneratedPackage(t *testing.T) {
+	goTool, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go tool not found")
+	}
+	pkg, err := parsePackage(filepath.Join("testdata", "users"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc, err := pkg.service("UserService")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a directory of the module, testdata keeps it out of ./...
+	dir, err := ioutil.TempDir("testdata", "build")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	src, err := ioutil.ReadFile(filepath.Join("testdata", "users", "users.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := &generator{pkg: pkg}
+	files := map[string][]byte{
+		"users.go":                   src,
+		"userservice_client.go":      g.client([]*service{svc}),
+		"userservice_client_test.go": g.tests([]*service{svc}),
+	}
+	for name, data := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, args := range [][]string{{"vet"}, {"test"}} {
+		cmd := exec.Command(goTool, append(args, "./"+filepath.ToSlash(dir))...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("go %s of the generated package: %v\n%s", args[0], err, out)
+		}
+	}
+}
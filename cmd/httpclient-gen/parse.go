@@ -0,0 +1,316 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+)
+
+var pathParamPattern = regexp.MustCompile(`\{([^{}/]+)\}`)
+
+type pkgInfo struct {
+	name  string
+	files []*ast.File
+}
+
+type service struct {
+	Name      string
+	ErrorType string
+	Methods   []*method
+	imports   map[string]string // package name -> import path used by the signatures
+}
+
+type method struct {
+	Name       string
+	HTTPMethod string
+	Path       string
+	Context    string // name of the context.Context parameter, if any
+	Params     []*param
+	Result     string // result type besides error, empty if none
+	Calls      []string
+	Bindings   []*binding
+}
+
+// binding is a parameter, or a constant header, bound to a part of the request.
+type binding struct {
+	Annotation string
+	Key        string
+	Param      *param // nil for a constant header
+	Value      string // of a constant header
+}
+
+type param struct {
+	Name string
+	Type string
+}
+
+func parsePackage(dir string) (*pkgInfo, error) {
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, dir, func(info os.FileInfo) bool {
+		return !strings.HasSuffix(info.Name(), "_test.go")
+	}, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	for name, pkg := range pkgs {
+		info := &pkgInfo{name: name}
+		for _, file := range pkg.Files {
+			info.files = append(info.files, file)
+		}
+		return info, nil
+	}
+	return nil, fmt.Errorf("no Go package in %s", dir)
+}
+
+func (pkg *pkgInfo) service(name string) (*service, error) {
+	for _, file := range pkg.files {
+		for _, decl := range file.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range gen.Specs {
+				ts := spec.(*ast.TypeSpec)
+				if ts.Name.Name != name {
+					continue
+				}
+				iface, ok := ts.Type.(*ast.InterfaceType)
+				if !ok {
+					return nil, fmt.Errorf("%s is not an interface", name)
+				}
+				doc := ts.Doc
+				if doc == nil {
+					doc = gen.Doc
+				}
+				return parseService(name, doc, iface, file)
+			}
+		}
+	}
+	return nil, fmt.Errorf("interface %s not found", name)
+}
+
+func annotations(doc *ast.CommentGroup) [][]string {
+	var result [][]string
+	if doc == nil {
+		return nil
+	}
+	for _, line := range strings.Split(doc.Text(), "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "@") {
+			continue
+		}
+		if i := strings.Index(line, ":"); i > 0 && strings.HasPrefix(line, "@Header ") {
+			// constant header, "@Header Key: value"
+			key := strings.TrimSpace(line[len("@Header "):i])
+			result = append(result, []string{"@Header", key + ":", strings.TrimSpace(line[i+1:])})
+			continue
+		}
+		result = append(result, strings.Fields(line))
+	}
+	return result
+}
+
+func parseService(name string, doc *ast.CommentGroup, iface *ast.InterfaceType, file *ast.File) (*service, error) {
+	svc := &service{Name: name, imports: map[string]string{}}
+	for _, a := range annotations(doc) {
+		if a[0] == "@Error" && len(a) == 2 {
+			svc.ErrorType = a[1]
+		}
+	}
+	fileImports := map[string]string{}
+	for _, imp := range file.Imports {
+		path := strings.Trim(imp.Path.Value, `"`)
+		pkgName := path[strings.LastIndex(path, "/")+1:]
+		if imp.Name != nil {
+			pkgName = imp.Name.Name
+		}
+		fileImports[pkgName] = path
+	}
+	for _, field := range iface.Methods.List {
+		ft, ok := field.Type.(*ast.FuncType)
+		if !ok || len(field.Names) == 0 {
+			return nil, fmt.Errorf("%s: embedded interfaces are not supported", name)
+		}
+		ast.Inspect(ft, func(n ast.Node) bool {
+			if sel, ok := n.(*ast.SelectorExpr); ok {
+				if id, ok := sel.X.(*ast.Ident); ok && fileImports[id.Name] != "" {
+					svc.imports[id.Name] = fileImports[id.Name]
+				}
+			}
+			return true
+		})
+		m, err := parseMethod(field.Names[0].Name, field.Doc, ft)
+		if err != nil {
+			return nil, fmt.Errorf("%s.%s: %s", name, field.Names[0].Name, err)
+		}
+		svc.Methods = append(svc.Methods, m)
+	}
+	return svc, nil
+}
+
+func parseMethod(name string, doc *ast.CommentGroup, ft *ast.FuncType) (*method, error) {
+	m := &method{Name: name}
+	for i, field := range ft.Params.List {
+		typ := types.ExprString(field.Type)
+		if len(field.Names) == 0 {
+			return nil, fmt.Errorf("parameters must be named")
+		}
+		for _, n := range field.Names {
+			if i == 0 && typ == "context.Context" {
+				m.Context = n.Name
+				continue
+			}
+			m.Params = append(m.Params, &param{Name: n.Name, Type: typ})
+		}
+	}
+	results := ft.Results
+	if results == nil || len(results.List) == 0 || types.ExprString(results.List[len(results.List)-1].Type) != "error" {
+		return nil, fmt.Errorf("the last result must be error")
+	}
+	switch results.NumFields() {
+	case 1:
+	case 2:
+		m.Result = types.ExprString(results.List[0].Type)
+	default:
+		return nil, fmt.Errorf("at most one result besides error is supported")
+	}
+
+	bound := map[string]bool{}
+	lookup := func(paramName string) (*param, error) {
+		for _, p := range m.Params {
+			if p.Name == paramName {
+				bound[paramName] = true
+				return p, nil
+			}
+		}
+		return nil, fmt.Errorf("unknown parameter %q", paramName)
+	}
+	// headers are set last, the form setters would replace a Content-Type set before them
+	var headerCalls []string
+	for _, a := range annotations(doc) {
+		verb := strings.ToUpper(strings.TrimPrefix(a[0], "@"))
+		switch verb {
+		case http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch,
+			http.MethodDelete, http.MethodHead, http.MethodOptions:
+			if len(a) != 2 {
+				return nil, fmt.Errorf("%s needs a path", a[0])
+			}
+			m.HTTPMethod, m.Path = verb, a[1]
+			continue
+		}
+		switch a[0] {
+		case "@Header":
+			if len(a) == 3 && strings.HasSuffix(a[1], ":") {
+				key := strings.TrimSuffix(a[1], ":")
+				headerCalls = append(headerCalls, fmt.Sprintf("Head(%q, %q)", key, a[2]))
+				m.Bindings = append(m.Bindings, &binding{Annotation: a[0], Key: key, Value: a[2]})
+				continue
+			}
+			fallthrough
+		case "@Query", "@Form":
+			if len(a) != 2 && len(a) != 3 {
+				return nil, fmt.Errorf("%s needs a key and an optional parameter", a[0])
+			}
+			p, err := lookup(a[len(a)-1])
+			if err != nil {
+				return nil, err
+			}
+			m.Bindings = append(m.Bindings, &binding{Annotation: a[0], Key: a[1], Param: p})
+			if a[0] == "@Header" {
+				headerCalls = append(headerCalls, valueCall(a[0], a[1], p))
+				continue
+			}
+			m.Calls = append(m.Calls, valueCall(a[0], a[1], p))
+		case "@Body", "@QueryStruct", "@FormStruct", "@HeaderStruct":
+			if len(a) != 2 {
+				return nil, fmt.Errorf("%s needs a parameter", a[0])
+			}
+			p, err := lookup(a[1])
+			if err != nil {
+				return nil, err
+			}
+			m.Bindings = append(m.Bindings, &binding{Annotation: a[0], Param: p})
+			call := strings.TrimPrefix(a[0], "@")
+			if call == "Body" {
+				call = "JSON"
+			}
+			if call == "HeaderStruct" {
+				headerCalls = append(headerCalls, fmt.Sprintf("%s(%s)", call, p.Name))
+				continue
+			}
+			m.Calls = append(m.Calls, fmt.Sprintf("%s(%s)", call, p.Name))
+		default:
+			return nil, fmt.Errorf("unknown annotation %s", a[0])
+		}
+	}
+	if m.HTTPMethod == "" {
+		return nil, fmt.Errorf("missing request line annotation such as @GET /path")
+	}
+	if m.binds("@Body") && m.bindsHeader("Content-Type") {
+		return nil, fmt.Errorf("@Header Content-Type conflicts with @Body, which is sent as application/json")
+	}
+	m.Calls = append(m.Calls, headerCalls...)
+	var pathCalls []string
+	for _, match := range pathParamPattern.FindAllStringSubmatch(m.Path, -1) {
+		p, err := lookup(match[1])
+		if err != nil {
+			return nil, fmt.Errorf("path %s: %s", m.Path, err)
+		}
+		pathCalls = append(pathCalls, fmt.Sprintf("PathParam(%q, %s)", match[1], stringExpr(p)))
+	}
+	m.Calls = append(pathCalls, m.Calls...)
+	for _, p := range m.Params {
+		if !bound[p.Name] {
+			return nil, fmt.Errorf("parameter %s is not bound by any annotation", p.Name)
+		}
+	}
+	return m, nil
+}
+
+// binds tells whether an annotation binds a parameter or a value of m.
+func (m *method) binds(annotation string) bool {
+	for _, b := range m.Bindings {
+		if b.Annotation == annotation {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *method) bindsHeader(key string) bool {
+	for _, b := range m.Bindings {
+		if b.Annotation == "@Header" && http.CanonicalHeaderKey(b.Key) == http.CanonicalHeaderKey(key) {
+			return true
+		}
+	}
+	return false
+}
+
+func valueCall(annotation string, key string, p *param) string {
+	switch annotation {
+	case "@Query":
+		if p.Type == "[]string" {
+			return fmt.Sprintf("QueryArray(%q, %s)", key, p.Name)
+		}
+		return fmt.Sprintf("Query(%q, %s)", key, stringExpr(p))
+	case "@Form":
+		if p.Type == "[]string" {
+			return fmt.Sprintf("ParamArray(%q, %s)", key, p.Name)
+		}
+		return fmt.Sprintf("Param(%q, %s)", key, stringExpr(p))
+	}
+	return fmt.Sprintf("Head(%q, %s)", key, stringExpr(p))
+}
+
+func stringExpr(p *param) string {
+	if p.Type == "string" {
+		return p.Name
+	}
+	return fmt.Sprintf("fmt.Sprint(%s)", p.Name)
+}
@@ -0,0 +1,46 @@
+package users
+
+import (
+	"context"
+	"time"
+)
+
+type User struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type APIError struct {
+	Message string `json:"message"`
+}
+
+func (e *APIError) Error() string { return e.Message }
+
+type ListOptions struct {
+	Page  int       `url:"page,omitempty"`
+	Since time.Time `url:"since,omitempty"`
+}
+
+// @Error APIError
+type UserService interface {
+	// @GET /users/{id}
+	// @Query page
+	GetUser(ctx context.Context, id string, page int) (*User, error)
+
+	// @GET /users
+	// @QueryStruct opts
+	// @Header Accept: application/json
+	ListUsers(ctx context.Context, opts *ListOptions) ([]User, error)
+
+	// @POST /users
+	// @Body user
+	CreateUser(ctx context.Context, user *User) (*User, error)
+
+	// @PUT /users/{id}/avatar
+	// @Header Content-Type contentType
+	// @Form tags
+	SetAvatar(id string, contentType string, tags []string) (string, error)
+
+	// @DELETE /users/{id}
+	DeleteUser(ctx context.Context, id string) error
+}
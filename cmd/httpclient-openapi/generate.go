@@ -0,0 +1,558 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+var pathParamPattern = regexp.MustCompile(`\{([^{}/]+)\}`)
+
+var initialisms = map[string]string{
+	"api": "API", "http": "HTTP", "https": "HTTPS", "id": "ID", "ip": "IP",
+	"json": "JSON", "uri": "URI", "url": "URL", "uuid": "UUID", "xml": "XML",
+}
+
+// goName converts an identifier from the spec into an exported Go name.
+func goName(s string) string {
+	var parts []string
+	word := []rune{}
+	flush := func() {
+		if len(word) > 0 {
+			parts = append(parts, string(word))
+			word = word[:0]
+		}
+	}
+	for i, r := range s {
+		switch {
+		case !unicode.IsLetter(r) && !unicode.IsDigit(r):
+			flush()
+		case unicode.IsUpper(r) && i > 0 && len(word) > 0 && unicode.IsLower(word[len(word)-1]):
+			flush()
+			word = append(word, r)
+		default:
+			word = append(word, r)
+		}
+	}
+	flush()
+	var b strings.Builder
+	for _, part := range parts {
+		if initialism, ok := initialisms[strings.ToLower(part)]; ok {
+			b.WriteString(initialism)
+			continue
+		}
+		runes := []rune(part)
+		b.WriteRune(unicode.ToUpper(runes[0]))
+		b.WriteString(string(runes[1:]))
+	}
+	name := b.String()
+	if name == "" {
+		return "Value"
+	}
+	if unicode.IsDigit([]rune(name)[0]) {
+		name = "N" + name
+	}
+	return name
+}
+
+// argName converts an identifier from the spec into an unexported Go name.
+func argName(s string) string {
+	name := goName(s)
+	for initialism := range initialisms {
+		if strings.ToLower(name) == initialism {
+			return initialism
+		}
+	}
+	runes := []rune(name)
+	runes[0] = unicode.ToLower(runes[0])
+	name = string(runes)
+	switch name {
+	case "ctx", "c", "resp", "result", "err", "params", "body", "type", "func", "range", "map", "default", "select", "package", "interface", "chan", "go", "var", "const":
+		name += "Value"
+	}
+	return name
+}
+
+type generator struct {
+	doc     *document
+	pkg     string
+	source  string
+	models  bytes.Buffer
+	methods bytes.Buffer
+	imports map[string]bool
+	defined map[string]bool
+}
+
+func newGenerator(doc *document, pkg string, source string) *generator {
+	return &generator{
+		doc:     doc,
+		pkg:     pkg,
+		source:  source,
+		imports: map[string]bool{"net/http": true, "strconv": true, "github.com/cocotyty/httpclient": true},
+		defined: map[string]bool{},
+	}
+}
+
+func comment(buf *bytes.Buffer, name string, texts ...string) {
+	first := true
+	for _, text := range texts {
+		text = strings.TrimSpace(text)
+		if text == "" {
+			continue
+		}
+		if !first {
+			buf.WriteString("//\n")
+		}
+		for i, line := range strings.Split(text, "\n") {
+			if first && i == 0 {
+				line = name + " " + line
+			}
+			fmt.Fprintf(buf, "// %s\n", strings.TrimRight(line, " \t"))
+		}
+		first = false
+	}
+}
+
+func (g *generator) generate() []byte {
+	var names []string
+	for name := range g.doc.Components.Schemas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		g.defineSchema(goName(name), g.doc.Components.Schemas[name])
+	}
+
+	var paths []string
+	for path := range g.doc.Paths {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		item := g.doc.Paths[path]
+		for _, op := range []struct {
+			method string
+			op     *operation
+		}{
+			{http.MethodGet, item.Get}, {http.MethodPut, item.Put}, {http.MethodPost, item.Post},
+			{http.MethodDelete, item.Delete}, {http.MethodOptions, item.Options},
+			{http.MethodHead, item.Head}, {http.MethodPatch, item.Patch},
+		} {
+			if op.op != nil {
+				g.operation(path, op.method, item, op.op)
+			}
+		}
+	}
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "// Code generated by httpclient-openapi from %s; DO NOT EDIT.\n\n", g.source)
+	if g.doc.Info.Title != "" {
+		fmt.Fprintf(&out, "// Package %s is a client for %s %s.\n", g.pkg, g.doc.Info.Title, g.doc.Info.Version)
+	}
+	fmt.Fprintf(&out, "package %s\n\n", g.pkg)
+	var std, thirdParty []string
+	for path := range g.imports {
+		if strings.Contains(strings.SplitN(path, "/", 2)[0], ".") {
+			thirdParty = append(thirdParty, path)
+		} else {
+			std = append(std, path)
+		}
+	}
+	sort.Strings(std)
+	sort.Strings(thirdParty)
+	out.WriteString("import (\n")
+	for _, path := range std {
+		fmt.Fprintf(&out, "\t%q\n", path)
+	}
+	out.WriteString("\n")
+	for _, path := range thirdParty {
+		fmt.Fprintf(&out, "\t%q\n", path)
+	}
+	out.WriteString(")\n\n")
+	out.WriteString(clientSource)
+	out.Write(g.methods.Bytes())
+	out.Write(g.models.Bytes())
+	return out.Bytes()
+}
+
+const clientSource = `// Client calls the API through httpclient.
+type Client struct {
+	newRequest func() *httpclient.HttpRequest
+}
+
+// NewClient returns a client sending requests to baseURL through cl, http.DefaultClient is used if cl is nil.
+func NewClient(baseURL string, cl *http.Client) *Client {
+	if cl == nil {
+		cl = http.DefaultClient
+	}
+	return NewClientWithFactory(func() *httpclient.HttpRequest {
+		return httpclient.NewHttpRequest(cl).BaseURL(baseURL)
+	})
+}
+
+// NewClientWithFactory returns a client whose requests are created by newRequest,
+// e.g. func() *httpclient.HttpRequest { return builder.Request(sessionID) }.
+func NewClientWithFactory(newRequest func() *httpclient.HttpRequest) *Client {
+	return &Client{newRequest: newRequest}
+}
+
+// APIError is returned for responses with a status code of 400 or above.
+// Model holds the body decoded into the type the spec declares for the status code, if any.
+type APIError struct {
+	*httpclient.StatusError
+	Model interface{}
+}
+
+func decodeError(err error, models map[string]func() interface{}) error {
+	statusErr, ok := err.(*httpclient.StatusError)
+	if !ok {
+		return err
+	}
+	code := strconv.Itoa(statusErr.Code)
+	newModel, ok := models[code]
+	if !ok {
+		newModel, ok = models[code[:1]+"XX"]
+	}
+	if !ok {
+		newModel, ok = models["default"]
+	}
+	apiErr := &APIError{StatusError: statusErr}
+	if ok {
+		model := newModel()
+		if statusErr.JSON(model) == nil {
+			apiErr.Model = model
+		}
+	}
+	return apiErr
+}
+`
+
+// isStruct reports whether s is generated as a Go struct.
+func (g *generator) isStruct(s *schema) bool {
+	s = g.doc.schema(s)
+	return s != nil && len(s.Enum) == 0 && (len(s.Properties) > 0 || len(s.AllOf) > 0 ||
+		s.Type == "object" && len(s.AdditionalProperties) == 0 && len(s.OneOf) == 0 && len(s.AnyOf) == 0)
+}
+
+// typeOf returns the Go type of s, inline structs and enums are defined with the name hint.
+func (g *generator) typeOf(s *schema, hint string) string {
+	if s == nil {
+		return "interface{}"
+	}
+	if s.Ref != "" {
+		return goName(refName(s.Ref))
+	}
+	if len(s.Enum) > 0 || g.isStruct(s) {
+		g.defineSchema(hint, s)
+		return hint
+	}
+	if len(s.OneOf) > 0 || len(s.AnyOf) > 0 {
+		g.imports["encoding/json"] = true
+		return "json.RawMessage"
+	}
+	switch s.Type {
+	case "string":
+		switch s.Format {
+		case "date-time":
+			g.imports["time"] = true
+			return "time.Time"
+		case "byte", "binary":
+			return "[]byte"
+		}
+		return "string"
+	case "integer":
+		if s.Format == "int32" {
+			return "int32"
+		}
+		return "int64"
+	case "number":
+		if s.Format == "float" {
+			return "float32"
+		}
+		return "float64"
+	case "boolean":
+		return "bool"
+	case "array":
+		return "[]" + g.fieldType(s.Items, hint+"Item", true)
+	case "object":
+		var additional schema
+		if len(s.AdditionalProperties) > 0 && json.Unmarshal(s.AdditionalProperties, &additional) == nil {
+			return "map[string]" + g.fieldType(&additional, hint+"Value", true)
+		}
+		return "map[string]interface{}"
+	}
+	return "interface{}"
+}
+
+// fieldType is typeOf with optional structs referenced through pointers.
+func (g *generator) fieldType(s *schema, hint string, required bool) string {
+	typ := g.typeOf(s, hint)
+	if !required && g.isStruct(s) || s != nil && s.Nullable && g.isStruct(s) {
+		return "*" + typ
+	}
+	return typ
+}
+
+func (g *generator) defineSchema(name string, s *schema) {
+	if g.defined[name] {
+		return
+	}
+	g.defined[name] = true
+	// nested definitions are written to g.models while this one is built
+	var buf bytes.Buffer
+	buf.WriteString("\n")
+	comment(&buf, name, s.Description)
+	switch {
+	case s.Ref != "":
+		fmt.Fprintf(&buf, "type %s = %s\n", name, goName(refName(s.Ref)))
+	case len(s.Enum) > 0:
+		defineEnum(&buf, name, s)
+	case g.isStruct(s):
+		var fields bytes.Buffer
+		g.structFields(&fields, name, s)
+		fmt.Fprintf(&buf, "type %s struct {\n%s}\n", name, fields.String())
+	default:
+		typ := g.typeOf(s, name+"Item")
+		fmt.Fprintf(&buf, "type %s %s\n", name, typ)
+	}
+	g.models.Write(buf.Bytes())
+}
+
+func defineEnum(buf *bytes.Buffer, name string, s *schema) {
+	base := "string"
+	switch s.Type {
+	case "integer":
+		base = "int64"
+	case "number":
+		base = "float64"
+	}
+	fmt.Fprintf(buf, "type %s %s\n\n", name, base)
+	fmt.Fprintf(buf, "const (\n")
+	for _, value := range s.Enum {
+		if value == nil {
+			continue
+		}
+		literal := fmt.Sprint(value)
+		if base == "string" {
+			literal = strconv.Quote(literal)
+		}
+		fmt.Fprintf(buf, "\t%s%s %s = %s\n", name, goName(fmt.Sprint(value)), name, literal)
+	}
+	fmt.Fprintf(buf, ")\n")
+}
+
+func (g *generator) structFields(buf *bytes.Buffer, name string, s *schema) {
+	for _, part := range s.AllOf {
+		if part.Ref != "" {
+			fmt.Fprintf(buf, "\t%s\n", goName(refName(part.Ref)))
+			continue
+		}
+		g.structFields(buf, name, part)
+	}
+	required := map[string]bool{}
+	for _, r := range s.Required {
+		required[r] = true
+	}
+	var props []string
+	for prop := range s.Properties {
+		props = append(props, prop)
+	}
+	sort.Strings(props)
+	for _, prop := range props {
+		field := goName(prop)
+		typ := g.fieldType(s.Properties[prop], name+field, required[prop])
+		tag := prop
+		if !required[prop] {
+			tag += ",omitempty"
+		}
+		if desc := strings.TrimSpace(s.Properties[prop].Description); desc != "" {
+			for _, line := range strings.Split(desc, "\n") {
+				fmt.Fprintf(buf, "\t// %s\n", strings.TrimRight(line, " \t"))
+			}
+		}
+		fmt.Fprintf(buf, "\t%s %s `json:%q`\n", field, typ, tag)
+	}
+}
+
+func (g *generator) operation(path string, method string, item *pathItem, op *operation) {
+	name := goName(op.OperationID)
+	if op.OperationID == "" {
+		name = goName(strings.ToLower(method) + " " + pathParamPattern.ReplaceAllString(path, "by $1"))
+	}
+
+	var params []*parameter
+	seen := map[string]int{}
+	for _, p := range append(append([]*parameter{}, item.Parameters...), op.Parameters...) {
+		p = g.doc.parameter(p)
+		key := p.In + ":" + p.Name
+		if i, ok := seen[key]; ok {
+			params[i] = p
+			continue
+		}
+		seen[key] = len(params)
+		params = append(params, p)
+	}
+
+	args := []string{"ctx context.Context"}
+	calls := []string{"Context(ctx)", fmt.Sprintf("Method(%q)", method), fmt.Sprintf("Path(%q)", path)}
+	g.imports["context"] = true
+	var query, header []*parameter
+	for _, p := range params {
+		switch p.In {
+		case "path":
+			arg := argName(p.Name)
+			typ := g.typeOf(p.Schema, name+goName(p.Name))
+			args = append(args, arg+" "+typ)
+			value := arg
+			if typ != "string" {
+				g.imports["fmt"] = true
+				value = fmt.Sprintf("fmt.Sprint(%s)", arg)
+			}
+			calls = append(calls, fmt.Sprintf("PathParam(%q, %s)", p.Name, value))
+		case "query":
+			query = append(query, p)
+		case "header":
+			header = append(header, p)
+		}
+	}
+	if len(query)+len(header) > 0 {
+		paramsType := name + "Params"
+		g.defineParams(paramsType, name, query, header)
+		args = append(args, "params *"+paramsType)
+		if len(query) > 0 {
+			calls = append(calls, "QueryStruct(params)")
+		}
+		if len(header) > 0 {
+			calls = append(calls, "HeaderStruct(params)")
+		}
+	}
+	if body := g.doc.requestBody(op.RequestBody); body != nil {
+		if s, ok := jsonSchema(body.Content); ok {
+			args = append(args, "body "+g.fieldType(s, name+"Request", false))
+			calls = append(calls, "JSON(body)")
+		} else {
+			var contentTypes []string
+			for contentType := range body.Content {
+				contentTypes = append(contentTypes, contentType)
+			}
+			sort.Strings(contentTypes)
+			args = append(args, "body []byte")
+			if len(contentTypes) > 0 {
+				calls = append(calls, fmt.Sprintf("Head(\"Content-Type\", %q)", contentTypes[0]))
+			}
+			calls = append(calls, "Body(body)")
+		}
+	}
+
+	result, errorModels := g.responses(name, op)
+	buf := &g.methods
+	buf.WriteString("\n")
+	comment(buf, name, op.Summary, op.Description)
+	if op.Deprecated {
+		if op.Summary == "" && op.Description == "" {
+			fmt.Fprintf(buf, "// %s is deprecated.\n", name)
+		}
+		buf.WriteString("//\n// Deprecated: the operation is deprecated by the API.\n")
+	}
+	results := "(err error)"
+	if result != "" {
+		results = fmt.Sprintf("(result %s, err error)", result)
+	}
+	fmt.Fprintf(buf, "func (c *Client) %s(%s) %s {\n", name, strings.Join(args, ", "), results)
+	buf.WriteString("\tresp := c.newRequest().\n")
+	for _, call := range calls {
+		fmt.Fprintf(buf, "\t\t%s.\n", call)
+	}
+	buf.WriteString("\t\tSend()\n")
+	buf.WriteString("\tif err = resp.CheckStatus(); err != nil {\n")
+	ret := "return decodeError"
+	if result != "" {
+		ret = "return result, decodeError"
+	}
+	if len(errorModels) == 0 {
+		fmt.Fprintf(buf, "\t\t%s(err, nil)\n\t}\n", ret)
+	} else {
+		fmt.Fprintf(buf, "\t\t%s(err, map[string]func() interface{}{\n", ret)
+		var codes []string
+		for code := range errorModels {
+			codes = append(codes, code)
+		}
+		sort.Strings(codes)
+		for _, code := range codes {
+			fmt.Fprintf(buf, "\t\t\t%q: func() interface{} { return new(%s) },\n", code, errorModels[code])
+		}
+		buf.WriteString("\t\t})\n\t}\n")
+	}
+	if result != "" {
+		buf.WriteString("\terr = resp.JSON(&result)\n")
+	}
+	buf.WriteString("\treturn\n}\n")
+}
+
+func (g *generator) defineParams(paramsType string, op string, query, header []*parameter) {
+	var fields bytes.Buffer
+	for _, p := range query {
+		g.paramField(&fields, op, p, fmt.Sprintf("url:%q header:\"-\"", tagValue(p)))
+	}
+	for _, p := range header {
+		g.paramField(&fields, op, p, fmt.Sprintf("url:\"-\" header:%q", tagValue(p)))
+	}
+	g.models.WriteString("\n")
+	fmt.Fprintf(&g.models, "// %s holds the query and header parameters of %s.\n", paramsType, op)
+	fmt.Fprintf(&g.models, "type %s struct {\n%s}\n", paramsType, fields.String())
+}
+
+func tagValue(p *parameter) string {
+	if p.Required {
+		return p.Name
+	}
+	return p.Name + ",omitempty"
+}
+
+func (g *generator) paramField(buf *bytes.Buffer, op string, p *parameter, tag string) {
+	field := goName(p.Name)
+	if desc := strings.TrimSpace(p.Description); desc != "" {
+		for _, line := range strings.Split(desc, "\n") {
+			fmt.Fprintf(buf, "\t// %s\n", strings.TrimRight(line, " \t"))
+		}
+	}
+	fmt.Fprintf(buf, "\t%s %s `%s`\n", field, g.fieldType(p.Schema, op+field, true), tag)
+}
+
+// responses returns the result type of the first successful response and the
+// error models keyed by status code.
+func (g *generator) responses(op string, o *operation) (string, map[string]string) {
+	var codes []string
+	for code := range o.Responses {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	result := ""
+	errorModels := map[string]string{}
+	for _, code := range codes {
+		resp := g.doc.response(o.Responses[code])
+		s, ok := jsonSchema(resp.Content)
+		if !ok || s == nil {
+			continue
+		}
+		if strings.HasPrefix(code, "2") {
+			if result == "" {
+				result = g.fieldType(s, op+"Response", false)
+			}
+			continue
+		}
+		suffix := "Default"
+		if code != "default" {
+			code = strings.ToUpper(code)
+			suffix = code
+		}
+		errorModels[code] = g.typeOf(s, op+"Error"+suffix)
+	}
+	return result, errorModels
+}
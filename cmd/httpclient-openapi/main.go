@@ -0,0 +1,69 @@
+// Command httpclient-openapi generates a typed client from an OpenAPI 3 document.
+//
+//	//go:generate go run github.com/cocotyty/httpclient/cmd/httpclient-openapi -spec petstore.yaml -package petstore
+//
+// Every schema in components becomes a Go type, enums become named types with constants.
+// Every operation becomes a method on Client built with the httpclient fluent API:
+// path parameters are arguments, query and header parameters are grouped into an
+// <Operation>Params struct and JSON request bodies are passed as body. Error responses
+// are returned as *APIError with the body decoded into the model declared for the status.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+var (
+	spec    = flag.String("spec", "", "OpenAPI 3 document, JSON or YAML; must be set")
+	pkgName = flag.String("package", "", "package name of the generated code; default the directory name")
+	output  = flag.String("output", "", "output file name; default client_gen.go")
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage of httpclient-openapi:\n")
+	fmt.Fprintf(os.Stderr, "\thttpclient-openapi -spec openapi.yaml [-package name] [-output file]\n")
+	flag.PrintDefaults()
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("httpclient-openapi: ")
+	flag.Usage = usage
+	flag.Parse()
+	if *spec == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	doc, err := loadDocument(*spec)
+	if err != nil {
+		log.Fatal(err)
+	}
+	outputName := *output
+	if outputName == "" {
+		outputName = "client_gen.go"
+	}
+	name := *pkgName
+	if name == "" {
+		dir, err := filepath.Abs(filepath.Dir(outputName))
+		if err != nil {
+			log.Fatal(err)
+		}
+		name = filepath.Base(dir)
+	}
+	src := newGenerator(doc, name, filepath.Base(*spec)).generate()
+	formatted, err := format.Source(src)
+	if err != nil {
+		log.Printf("warning: internal error: invalid Go generated: %s", err)
+		log.Printf("warning: compile the package to analyze the error")
+		formatted = src
+	}
+	if err := ioutil.WriteFile(outputName, formatted, 0644); err != nil {
+		log.Fatalf("writing output: %s", err)
+	}
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+type document struct {
+	OpenAPI string `json:"openapi"`
+	Info    struct {
+		Title   string `json:"title"`
+		Version string `json:"version"`
+	} `json:"info"`
+	Paths      map[string]*pathItem `json:"paths"`
+	Components components           `json:"components"`
+}
+
+type components struct {
+	Schemas       map[string]*schema      `json:"schemas"`
+	Parameters    map[string]*parameter   `json:"parameters"`
+	RequestBodies map[string]*requestBody `json:"requestBodies"`
+	Responses     map[string]*response    `json:"responses"`
+}
+
+type pathItem struct {
+	Parameters []*parameter `json:"parameters"`
+	Get        *operation   `json:"get"`
+	Put        *operation   `json:"put"`
+	Post       *operation   `json:"post"`
+	Delete     *operation   `json:"delete"`
+	Options    *operation   `json:"options"`
+	Head       *operation   `json:"head"`
+	Patch      *operation   `json:"patch"`
+}
+
+type operation struct {
+	OperationID string               `json:"operationId"`
+	Summary     string               `json:"summary"`
+	Description string               `json:"description"`
+	Deprecated  bool                 `json:"deprecated"`
+	Parameters  []*parameter         `json:"parameters"`
+	RequestBody *requestBody         `json:"requestBody"`
+	Responses   map[string]*response `json:"responses"`
+}
+
+type parameter struct {
+	Ref         string  `json:"$ref"`
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Required    bool    `json:"required"`
+	Description string  `json:"description"`
+	Schema      *schema `json:"schema"`
+}
+
+type requestBody struct {
+	Ref      string                `json:"$ref"`
+	Required bool                  `json:"required"`
+	Content  map[string]*mediaType `json:"content"`
+}
+
+type response struct {
+	Ref         string                `json:"$ref"`
+	Description string                `json:"description"`
+	Content     map[string]*mediaType `json:"content"`
+}
+
+type mediaType struct {
+	Schema *schema `json:"schema"`
+}
+
+type schema struct {
+	Ref                  string             `json:"$ref"`
+	Type                 string             `json:"type"`
+	Format               string             `json:"format"`
+	Description          string             `json:"description"`
+	Properties           map[string]*schema `json:"properties"`
+	Required             []string           `json:"required"`
+	Items                *schema            `json:"items"`
+	Enum                 []interface{}      `json:"enum"`
+	AllOf                []*schema          `json:"allOf"`
+	OneOf                []*schema          `json:"oneOf"`
+	AnyOf                []*schema          `json:"anyOf"`
+	AdditionalProperties json.RawMessage    `json:"additionalProperties"`
+	Nullable             bool               `json:"nullable"`
+}
+
+func loadDocument(name string) (*document, error) {
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".yaml", ".yml":
+		var v interface{}
+		if err := yaml.Unmarshal(data, &v); err != nil {
+			return nil, err
+		}
+		if data, err = json.Marshal(jsonCompatible(v)); err != nil {
+			return nil, err
+		}
+	}
+	doc := &document{}
+	if err := json.Unmarshal(data, doc); err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(doc.OpenAPI, "3.") {
+		return nil, fmt.Errorf("%s: unsupported openapi version %q", name, doc.OpenAPI)
+	}
+	return doc, nil
+}
+
+// jsonCompatible converts the map[interface{}]interface{} produced by yaml into map[string]interface{}.
+func jsonCompatible(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			m[fmt.Sprint(k)] = jsonCompatible(item)
+		}
+		return m
+	case []interface{}:
+		for i, item := range v {
+			v[i] = jsonCompatible(item)
+		}
+	}
+	return v
+}
+
+func refName(ref string) string {
+	return ref[strings.LastIndex(ref, "/")+1:]
+}
+
+func (doc *document) parameter(p *parameter) *parameter {
+	if p.Ref != "" {
+		if resolved, ok := doc.Components.Parameters[refName(p.Ref)]; ok {
+			return resolved
+		}
+	}
+	return p
+}
+
+func (doc *document) requestBody(b *requestBody) *requestBody {
+	if b != nil && b.Ref != "" {
+		if resolved, ok := doc.Components.RequestBodies[refName(b.Ref)]; ok {
+			return resolved
+		}
+	}
+	return b
+}
+
+func (doc *document) response(r *response) *response {
+	if r != nil && r.Ref != "" {
+		if resolved, ok := doc.Components.Responses[refName(r.Ref)]; ok {
+			return resolved
+		}
+	}
+	return r
+}
+
+// schema follows the $ref of s, stopping at the last schema before a cycle.
+func (doc *document) schema(s *schema) *schema {
+	visited := map[string]bool{}
+	for s != nil && s.Ref != "" && !visited[s.Ref] {
+		visited[s.Ref] = true
+		resolved, ok := doc.Components.Schemas[refName(s.Ref)]
+		if !ok {
+			return s
+		}
+		s = resolved
+	}
+	return s
+}
+
+// jsonSchema returns the schema of application/json in content, or of the first +json media type.
+func jsonSchema(content map[string]*mediaType) (*schema, bool) {
+	if media, ok := content["application/json"]; ok {
+		return media.Schema, true
+	}
+	var contentTypes []string
+	for contentType := range content {
+		if strings.HasSuffix(contentType, "+json") {
+			contentTypes = append(contentTypes, contentType)
+		}
+	}
+	if len(contentTypes) == 0 {
+		return nil, false
+	}
+	sort.Strings(contentTypes)
+	return content[contentTypes[0]].Schema, true
+}
@@ -0,0 +1,74 @@
+package crawler
+
+import (
+	"hash/fnv"
+	"math"
+)
+
+// a zero BloomFilter holds a million strings with a false positive rate of 1%
+const (
+	defaultBloomSize = 1000000
+	defaultBloomRate = 0.01
+)
+
+// BloomFilter is a set of strings in a fixed memory that may report strings it was never given,
+// at the false positive rate it was made for. It is not safe for concurrent use.
+type BloomFilter struct {
+	Bits []uint64 `json:"bits"`
+	K    int      `json:"k"` // number of hashes
+}
+
+// NewBloomFilter makes a filter holding n strings with the false positive rate p.
+func NewBloomFilter(n int, p float64) *BloomFilter {
+	if n < 1 {
+		n = 1
+	}
+	if p <= 0 || p >= 1 {
+		p = 0.01
+	}
+	m := math.Ceil(-float64(n) * math.Log(p) / (math.Ln2 * math.Ln2))
+	k := int(math.Round(m / float64(n) * math.Ln2))
+	if k < 1 {
+		k = 1
+	}
+	return &BloomFilter{Bits: make([]uint64, (int(m)+63)/64), K: k}
+}
+
+// Add adds s to the filter.
+func (b *BloomFilter) Add(s string) {
+	b.init()
+	h1, h2, m := b.hashes(s)
+	for i := uint64(0); i < uint64(b.K); i++ {
+		bit := (h1 + i*h2) % m
+		b.Bits[bit/64] |= 1 << (bit % 64)
+	}
+}
+
+// Test reports whether s may have been added, false means it never was.
+func (b *BloomFilter) Test(s string) bool {
+	b.init()
+	h1, h2, m := b.hashes(s)
+	for i := uint64(0); i < uint64(b.K); i++ {
+		bit := (h1 + i*h2) % m
+		if b.Bits[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (b *BloomFilter) init() {
+	if len(b.Bits) == 0 {
+		*b = *NewBloomFilter(defaultBloomSize, defaultBloomRate)
+	} else if b.K < 1 {
+		b.K = 1
+	}
+}
+
+// hashes derives the k hashes from the two halves of a 64-bit hash.
+func (b *BloomFilter) hashes(s string) (h1, h2, m uint64) {
+	hash := fnv.New64a()
+	_, _ = hash.Write([]byte(s))
+	sum := hash.Sum64()
+	return sum & math.MaxUint32, sum>>32 | 1, uint64(len(b.Bits)) * 64
+}
@@ -0,0 +1,458 @@
+// Package crawler crawls sites within the sessions of a httpclient.Builder.
+package crawler
+
+import (
+	"container/heap"
+	"context"
+	"encoding/json"
+	"errors"
+	"mime"
+	"net/url"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/cocotyty/httpclient"
+)
+
+const (
+	defaultDelay    = time.Second
+	defaultStateTTL = 24 * time.Hour
+)
+
+var (
+	// ErrPaused is returned by Run when the crawl is paused.
+	ErrPaused = errors.New("crawler: paused")
+	// ErrRunning is returned by Run when the crawler is already running.
+	ErrRunning = errors.New("crawler: already running")
+	// ErrStopped is returned by Run when the crawl stopped before its end, neither paused nor canceled.
+	ErrStopped = errors.New("crawler: stopped before the end of the crawl")
+)
+
+// Handler handles a page whose url matches the pattern it is registered with.
+type Handler func(page *Page) error
+
+// Page is a fetched page.
+type Page struct {
+	*httpclient.HttpResponse
+	Request Request
+	crawler *Crawler
+}
+
+// Follow enqueues ref, resolved against the page, one level deeper than the page.
+// It reports whether it was enqueued: it is an http url within MaxDepth not seen before.
+func (p *Page) Follow(ref string, priority int) bool {
+	base := p.URL()
+	if base == nil {
+		base = &url.URL{}
+	}
+	u, err := base.Parse(ref)
+	if err != nil {
+		return false
+	}
+	return p.crawler.enqueue(u, p.Request.Depth+1, priority)
+}
+
+type route struct {
+	pattern *regexp.Regexp
+	handler Handler
+}
+
+// Crawler crawls from seed urls, following the links of the pages that match the patterns of its handlers.
+type Crawler struct {
+	Builder   *httpclient.Builder
+	SessionID string
+	Workers   int // 1 if zero
+	MaxDepth  int // of the pages from the seeds, unlimited if zero
+	MaxPages  int // unlimited if zero
+	// Delay between two requests to a host, 1s if zero, none if negative.
+	Delay      time.Duration
+	HostDelays map[string]time.Duration // Delay by host
+	// UserAgent is sent with the requests and matched against robots.txt,
+	// the UserAgentsPool of the Builder picks one for the session if empty.
+	UserAgent string
+	// Robots keeps the robots.txt of the crawled sites, those of the Builder or
+	// of the Cache of the Builder if nil. Their Crawl-delay is honored when longer than Delay.
+	Robots       *httpclient.Robots
+	IgnoreRobots bool
+	// Bloom dedupes the urls instead of a set of the canonical urls seen, to crawl in bounded memory.
+	// A zero BloomFilter holds a million urls.
+	Bloom *BloomFilter
+	// Priority of the links followed, 0 if nil.
+	Priority func(u *url.URL, depth int) int
+	// StateKey is the key of the state saved in the Cache of the Builder when the crawl stops,
+	// "crawler/" followed by the SessionID if empty. No state is saved if the Builder has no Cache.
+	StateKey string
+	StateTTL time.Duration // 24h if zero
+	// OnError is called with the requests that failed, and with a *httpclient.RobotsError
+	// for those robots.txt disallows.
+	OnError func(req Request, err error)
+
+	routes []route
+
+	mu       sync.Mutex
+	cond     *sync.Cond
+	frontier frontier
+	seen     map[string]bool
+	seq      uint64
+	pages    int
+	inFlight int
+	hosts    map[string]time.Time // when the next request to a host may be sent
+	running  bool
+	paused   bool
+	cancel   context.CancelFunc
+	robots   *httpclient.Robots
+}
+
+// state is what is saved of a stopped crawl.
+type state struct {
+	Frontier []Request    `json:"frontier"`
+	Seen     []string     `json:"seen,omitempty"`
+	Bloom    *BloomFilter `json:"bloom,omitempty"`
+	Seq      uint64       `json:"seq"`
+	Pages    int          `json:"pages"`
+}
+
+// Handle handles the pages whose canonical url matches the regular expression pattern with handler.
+// The first matching pattern wins. Links are followed if they match a pattern.
+func (c *Crawler) Handle(pattern string, handler Handler) *Crawler {
+	c.routes = append(c.routes, route{pattern: regexp.MustCompile(pattern), handler: handler})
+	return c
+}
+
+// Add enqueues a seed url, it reports whether it was not seen before.
+func (c *Crawler) Add(rawURL string, priority int) (bool, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return false, errors.New("crawler: not an http url: " + rawURL)
+	}
+	return c.enqueue(u, 0, priority), nil
+}
+
+func (c *Crawler) init() {
+	if c.cond == nil {
+		c.cond = sync.NewCond(&c.mu)
+		c.seen = map[string]bool{}
+		c.hosts = map[string]time.Time{}
+		switch {
+		case c.Robots != nil:
+			c.robots = c.Robots
+		case c.Builder.Robots != nil:
+			c.robots = c.Builder.Robots
+		default:
+			c.robots = &httpclient.Robots{Cache: c.Builder.Cache}
+		}
+	}
+}
+
+func (c *Crawler) enqueue(u *url.URL, depth, priority int) bool {
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" || c.MaxDepth > 0 && depth > c.MaxDepth {
+		return false
+	}
+	canonical := httpclient.Canonicalize(u).String()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.init()
+	if c.Bloom != nil {
+		if c.Bloom.Test(canonical) {
+			return false
+		}
+		c.Bloom.Add(canonical)
+	} else {
+		if c.seen[canonical] {
+			return false
+		}
+		c.seen[canonical] = true
+	}
+	c.seq++
+	heap.Push(&c.frontier, Request{URL: canonical, Depth: depth, Priority: priority, Seq: c.seq})
+	c.cond.Broadcast()
+	return true
+}
+
+// Run crawls until the frontier is empty, MaxPages are fetched, ctx is done or the crawl is paused.
+// A crawl stopped before its end saves its state to be resumed.
+func (c *Crawler) Run(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	c.mu.Lock()
+	c.init()
+	if c.running {
+		c.mu.Unlock()
+		return ErrRunning
+	}
+	c.running, c.paused, c.cancel = true, false, cancel
+	c.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		c.mu.Lock()
+		c.cond.Broadcast()
+		c.mu.Unlock()
+	}()
+	workers := c.Workers
+	if workers <= 0 {
+		workers = 1
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				req, ok := c.next(ctx)
+				if !ok {
+					return
+				}
+				c.visit(ctx, req)
+				c.mu.Lock()
+				c.inFlight--
+				c.cond.Broadcast()
+				c.mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.running = false
+	finished := c.frontier.Len() == 0 || c.MaxPages > 0 && c.pages >= c.MaxPages
+	if finished {
+		c.saveState(nil)
+		return nil
+	}
+	data, err := json.Marshal(c.snapshot())
+	if err != nil {
+		return err
+	}
+	c.saveState(data)
+	if c.paused {
+		return ErrPaused
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return ErrStopped
+}
+
+// Pause stops the crawl, Run returns ErrPaused once the state is saved.
+func (c *Crawler) Pause() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.running {
+		c.paused = true
+		c.cancel()
+	}
+}
+
+// Resume restores the state saved by a stopped crawl, if any, and runs the crawl.
+func (c *Crawler) Resume(ctx context.Context) error {
+	if err := c.restore(); err != nil {
+		return err
+	}
+	return c.Run(ctx)
+}
+
+// next waits for a request to fetch, ok is false when the crawl is over.
+func (c *Crawler) next(ctx context.Context) (req Request, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// the requests in flight may add to the frontier, or fail and leave room for more pages
+	for ctx.Err() == nil && c.inFlight > 0 && (c.frontier.Len() == 0 || c.MaxPages > 0 && c.pages+c.inFlight >= c.MaxPages) {
+		c.cond.Wait()
+	}
+	if ctx.Err() != nil || c.frontier.Len() == 0 || c.MaxPages > 0 && c.pages >= c.MaxPages {
+		return req, false
+	}
+	c.inFlight++
+	return heap.Pop(&c.frontier).(Request), true
+}
+
+// requeue puts back a request interrupted by the end of the crawl.
+func (c *Crawler) requeue(req Request) {
+	c.mu.Lock()
+	heap.Push(&c.frontier, req)
+	c.mu.Unlock()
+}
+
+func (c *Crawler) visit(ctx context.Context, req Request) {
+	u, err := url.Parse(req.URL)
+	if err != nil {
+		c.fail(req, err)
+		return
+	}
+	httpReq := c.request(ctx, req.URL)
+	var crawlDelay time.Duration
+	if !c.IgnoreRobots {
+		robots, err := c.robots.RobotsTxt(httpReq, u)
+		if err != nil {
+			if ctx.Err() != nil {
+				c.requeue(req)
+			} else {
+				c.fail(req, err)
+			}
+			return
+		}
+		if !robots.Allowed(httpReq.UserAgent(), u.RequestURI()) {
+			c.fail(req, &httpclient.RobotsError{URL: u, UserAgent: httpReq.UserAgent()})
+			return
+		}
+		crawlDelay = robots.CrawlDelay(httpReq.UserAgent())
+	}
+	if err := c.wait(ctx, u.Host, crawlDelay); err != nil {
+		c.requeue(req)
+		return
+	}
+	resp := httpReq.Send()
+	if _, err := resp.Code(); err != nil {
+		if ctx.Err() != nil {
+			c.requeue(req)
+			return
+		}
+		c.fail(req, err)
+		return
+	}
+	c.mu.Lock()
+	c.pages++
+	c.mu.Unlock()
+
+	page := &Page{HttpResponse: resp, Request: req, crawler: c}
+	if handler := c.handler(req.URL); handler != nil {
+		if err := handler(page); err != nil {
+			c.fail(req, err)
+		}
+	}
+	if mediaType, _, _ := mime.ParseMediaType(resp.Header().Get("Content-Type")); mediaType != "text/html" {
+		return
+	}
+	links, err := resp.Links()
+	if err != nil {
+		return
+	}
+	for _, link := range links {
+		if c.handler(httpclient.Canonicalize(link).String()) == nil {
+			continue
+		}
+		priority := 0
+		if c.Priority != nil {
+			priority = c.Priority(link, req.Depth+1)
+		}
+		c.enqueue(link, req.Depth+1, priority)
+	}
+}
+
+func (c *Crawler) request(ctx context.Context, rawURL string) *httpclient.HttpRequest {
+	req := c.Builder.Get(c.SessionID).Context(ctx).Url(rawURL)
+	if c.UserAgent != "" {
+		return req.UserAgentInHeader(c.UserAgent)
+	}
+	return req.AutoSelectUserAgent()
+}
+
+func (c *Crawler) handler(canonical string) Handler {
+	for _, route := range c.routes {
+		if route.pattern.MatchString(canonical) {
+			return route.handler
+		}
+	}
+	return nil
+}
+
+func (c *Crawler) fail(req Request, err error) {
+	if c.OnError != nil {
+		c.OnError(req, err)
+	}
+}
+
+// wait waits until a request may be sent to host, at least minDelay after the previous one.
+func (c *Crawler) wait(ctx context.Context, host string, minDelay time.Duration) error {
+	delay, ok := c.HostDelays[host]
+	if !ok {
+		delay = c.Delay
+	}
+	if delay == 0 {
+		delay = defaultDelay
+	}
+	if delay < minDelay {
+		delay = minDelay
+	}
+	if delay <= 0 {
+		return ctx.Err()
+	}
+	c.mu.Lock()
+	now := time.Now()
+	at := c.hosts[host]
+	if at.Before(now) {
+		at = now
+	}
+	c.hosts[host] = at.Add(delay)
+	c.mu.Unlock()
+
+	timer := time.NewTimer(at.Sub(now))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (c *Crawler) stateKey() string {
+	if c.StateKey != "" {
+		return c.StateKey
+	}
+	return "crawler/" + c.SessionID
+}
+
+func (c *Crawler) snapshot() state {
+	s := state{Frontier: append([]Request(nil), c.frontier...), Bloom: c.Bloom, Seq: c.seq, Pages: c.pages}
+	if c.Bloom == nil {
+		for canonical := range c.seen {
+			s.Seen = append(s.Seen, canonical)
+		}
+	}
+	return s
+}
+
+func (c *Crawler) saveState(data []byte) {
+	ttl := c.StateTTL
+	if ttl == 0 {
+		ttl = defaultStateTTL
+	}
+	if c.Builder.Cache != nil {
+		c.Builder.Cache.Set(c.stateKey(), data, ttl)
+	}
+}
+
+func (c *Crawler) restore() error {
+	if c.Builder.Cache == nil {
+		return nil
+	}
+	cached, found := c.Builder.Cache.Get(c.stateKey())
+	data, _ := cached.([]byte)
+	if !found || data == nil {
+		return nil
+	}
+	var s state
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.init()
+	c.frontier = frontier(s.Frontier)
+	heap.Init(&c.frontier)
+	if s.Bloom != nil {
+		c.Bloom = s.Bloom
+	}
+	for _, canonical := range s.Seen {
+		c.seen[canonical] = true
+	}
+	c.seq, c.pages = s.Seq, s.Pages
+	return nil
+}
@@ -0,0 +1,307 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cocotyty/httpclient"
+)
+
+// memCache is a Cache in memory for the tests, expiry ignored.
+type memCache struct {
+	mu    sync.Mutex
+	items map[string]interface{}
+}
+
+func (c *memCache) Get(key string) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	v, ok := c.items[key]
+	return v, ok
+}
+
+func (c *memCache) Set(key string, value interface{}, _ time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.items[key] = value
+}
+
+// site serves two hosts whose pages link to each other in cycles.
+type site struct {
+	a, b *httptest.Server
+
+	mu      sync.Mutex
+	fetched []string             // host letter and path of the pages, in order
+	times   map[string]time.Time // of the fetches by host letter and path
+}
+
+func newSite() *site {
+	s := &site{times: map[string]time.Time{}}
+	pages := map[string]map[string]string{
+		"a": {
+			"/":          `<a href="/a">a</a> <a href="/b">b</a> <a href="/private/x">x</a> <a href="{b}/">b host</a> <a href="/#top">self</a>`,
+			"/a":         `<a href="/">home</a> <a href="/c">c</a>`,
+			"/b":         `<a href="/a">a</a>`,
+			"/c":         `<a href="/d">d</a>`,
+			"/d":         `leaf`,
+			"/private/x": `secret`,
+		},
+		"b": {
+			"/":  `<a href="{a}/">a host</a> <a href="/e">e</a>`,
+			"/e": `leaf`,
+		},
+	}
+	handler := func(host string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/robots.txt" {
+				if host == "a" {
+					w.Write([]byte("User-agent: *\nDisallow: /private\n"))
+					return
+				}
+				http.NotFound(w, r)
+				return
+			}
+			s.mu.Lock()
+			s.fetched = append(s.fetched, host+r.URL.Path)
+			s.times[host+r.URL.Path] = time.Now()
+			s.mu.Unlock()
+			body, ok := pages[host][r.URL.Path]
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			body = strings.NewReplacer("{a}", s.a.URL, "{b}", s.b.URL).Replace(body)
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Write([]byte("<html><body>" + body + "</body></html>"))
+		}
+	}
+	s.a = httptest.NewServer(handler("a"))
+	s.b = httptest.NewServer(handler("b"))
+	return s
+}
+
+func (s *site) close() {
+	s.a.Close()
+	s.b.Close()
+}
+
+func (s *site) pages() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.fetched...)
+}
+
+// page returns the host letter and path of a page url.
+func (s *site) page(rawURL string) string {
+	u, _ := url.Parse(rawURL)
+	if "http://"+u.Host == s.b.URL {
+		return "b" + u.Path
+	}
+	return "a" + u.Path
+}
+
+func newCrawler(cache *memCache) *Crawler {
+	return &Crawler{
+		Builder:   &httpclient.Builder{Cache: cache},
+		SessionID: "test",
+		UserAgent: "testbot",
+		Delay:     -1,
+	}
+}
+
+func sorted(pages []string) []string {
+	pages = append([]string(nil), pages...)
+	sort.Strings(pages)
+	return pages
+}
+
+func TestCrawl(t *testing.T) {
+	s := newSite()
+	defer s.close()
+	c := newCrawler(&memCache{items: map[string]interface{}{}})
+	var mu sync.Mutex
+	handled := map[string]string{}
+	record := func(name string) Handler {
+		return func(page *Page) error {
+			mu.Lock()
+			defer mu.Unlock()
+			handled[s.page(page.Request.URL)] = name
+			return nil
+		}
+	}
+	c.Handle(`/b$`, record("b")).Handle(`.*`, record("any"))
+	c.Workers = 3
+	if _, err := c.Add(s.a.URL+"/", 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// every page once despite the cycles, none disallowed by robots.txt
+	want := []string{"a/", "a/a", "a/b", "a/c", "a/d", "b/", "b/e"}
+	if got := sorted(s.pages()); !reflect.DeepEqual(got, want) {
+		t.Errorf("fetched %q, want %q", got, want)
+	}
+	wantHandled := map[string]string{"a/": "any", "a/a": "any", "a/b": "b", "a/c": "any", "a/d": "any", "b/": "any", "b/e": "any"}
+	if !reflect.DeepEqual(handled, wantHandled) {
+		t.Errorf("handled %v, want %v", handled, wantHandled)
+	}
+}
+
+func TestCrawlDepthAndPriority(t *testing.T) {
+	s := newSite()
+	defer s.close()
+	c := newCrawler(&memCache{items: map[string]interface{}{}})
+	c.Handle(`.*`, func(*Page) error { return nil })
+	c.MaxDepth = 1
+	c.Priority = func(u *url.URL, depth int) int {
+		if u.Path == "/b" {
+			return 1
+		}
+		return 0
+	}
+	c.Add(s.a.URL+"/", 0)
+	if err := c.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	// /b first for its priority, then in the order the links were found, nothing deeper than 1
+	want := []string{"a/", "a/b", "a/a", "b/"}
+	if got := s.pages(); !reflect.DeepEqual(got, want) {
+		t.Errorf("fetched %q, want %q", got, want)
+	}
+}
+
+func TestCrawlHostDelay(t *testing.T) {
+	s := newSite()
+	defer s.close()
+	c := newCrawler(&memCache{items: map[string]interface{}{}})
+	c.Handle(`.*`, func(*Page) error { return nil })
+	const delay = 30 * time.Millisecond
+	c.Delay = delay
+	c.HostDelays = map[string]time.Duration{strings.TrimPrefix(s.b.URL, "http://"): -1}
+	c.Workers = 4
+	c.Add(s.a.URL+"/", 0)
+	if err := c.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	var times []time.Time
+	for page, at := range s.times {
+		if strings.HasPrefix(page, "a") {
+			times = append(times, at)
+		}
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+	for i := 1; i < len(times); i++ {
+		// the server sees the requests a little after they are sent
+		if gap := times[i].Sub(times[i-1]); gap < delay-5*time.Millisecond {
+			t.Errorf("requests to host a %s apart, want at least %s", gap, delay)
+		}
+	}
+}
+
+func TestCrawlMaxPages(t *testing.T) {
+	s := newSite()
+	defer s.close()
+	dead := httptest.NewServer(http.NotFoundHandler())
+	dead.Close()
+	c := newCrawler(&memCache{items: map[string]interface{}{}})
+	c.Handle(`.*`, func(*Page) error { return nil })
+	c.Workers = 4
+	c.MaxPages = 3
+	// the failures leave room for the pages of the site
+	for i := 0; i < 3; i++ {
+		c.Add(fmt.Sprintf("%s/%d", dead.URL, i), 1)
+	}
+	c.Add(s.a.URL+"/", 0)
+	if err := c.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.pages(); len(got) != 3 {
+		t.Errorf("fetched %q, want 3 pages", got)
+	}
+}
+
+func TestPauseResume(t *testing.T) {
+	s := newSite()
+	defer s.close()
+	cache := &memCache{items: map[string]interface{}{}}
+	var mu sync.Mutex
+	visited := 0
+	c := newCrawler(cache)
+	c.Handle(`.*`, func(*Page) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if visited++; visited == 3 {
+			c.Pause()
+		}
+		return nil
+	})
+	c.Add(s.a.URL+"/", 0)
+	if err := c.Run(context.Background()); err != ErrPaused {
+		t.Fatalf("Run = %v, want ErrPaused", err)
+	}
+	if data, _ := cache.Get("crawler/test"); data.([]byte) == nil {
+		t.Fatal("no state saved")
+	}
+	if got := len(s.pages()); got != 3 {
+		t.Fatalf("fetched %d pages before the pause, want 3", got)
+	}
+
+	resumed := newCrawler(cache)
+	resumed.Handle(`.*`, func(*Page) error { return nil })
+	if err := resumed.Resume(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"a/", "a/a", "a/b", "a/c", "a/d", "b/", "b/e"}
+	if got := sorted(s.pages()); !reflect.DeepEqual(got, want) {
+		t.Errorf("fetched %q, want every page once %q", got, want)
+	}
+	if data, _ := cache.Get("crawler/test"); data.([]byte) != nil {
+		t.Errorf("state %s kept after the end of the crawl", data)
+	}
+}
+
+func TestCrawlRobotsDisallowed(t *testing.T) {
+	s := newSite()
+	defer s.close()
+	c := newCrawler(&memCache{items: map[string]interface{}{}})
+	c.Handle(`.*`, func(*Page) error { return nil })
+	c.Bloom = &BloomFilter{}
+	var mu sync.Mutex
+	var disallowed []string
+	c.OnError = func(req Request, err error) {
+		if _, ok := err.(*httpclient.RobotsError); ok {
+			mu.Lock()
+			disallowed = append(disallowed, s.page(req.URL))
+			mu.Unlock()
+		}
+	}
+	c.Add(s.a.URL+"/", 0)
+	if err := c.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a/private/x"}; !reflect.DeepEqual(disallowed, want) {
+		t.Errorf("disallowed %q, want %q", disallowed, want)
+	}
+	want := []string{"a/", "a/a", "a/b", "a/c", "a/d", "b/", "b/e"}
+	if got := sorted(s.pages()); !reflect.DeepEqual(got, want) {
+		t.Errorf("fetched %q, want %q", got, want)
+	}
+}
+
+func TestResumeWithoutCache(t *testing.T) {
+	c := &Crawler{Builder: &httpclient.Builder{}, SessionID: "test"}
+	if err := c.Resume(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
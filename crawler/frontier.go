@@ -0,0 +1,32 @@
+package crawler
+
+// Request is an url waiting in the frontier.
+type Request struct {
+	URL      string `json:"url"`
+	Depth    int    `json:"depth"`    // 0 for the seeds
+	Priority int    `json:"priority"` // higher first
+	Seq      uint64 `json:"seq"`      // order of arrival among equal priorities
+}
+
+// frontier is a heap of requests, the highest priority first, then the first enqueued.
+type frontier []Request
+
+func (f frontier) Len() int { return len(f) }
+
+func (f frontier) Less(i, j int) bool {
+	if f[i].Priority != f[j].Priority {
+		return f[i].Priority > f[j].Priority
+	}
+	return f[i].Seq < f[j].Seq
+}
+
+func (f frontier) Swap(i, j int) { f[i], f[j] = f[j], f[i] }
+
+func (f *frontier) Push(x interface{}) { *f = append(*f, x.(Request)) }
+
+func (f *frontier) Pop() interface{} {
+	old := *f
+	req := old[len(old)-1]
+	*f = old[:len(old)-1]
+	return req
+}
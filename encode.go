@@ -20,7 +20,7 @@ func encodeQuery(source [][]string, encoding encoding.Encoding) []byte {
 		buf.WriteByte('&')
 	}
 	result := buf.Bytes()
-	if result[len(result)-1] == '&' {
+	if len(result) > 0 && result[len(result)-1] == '&' {
 		result = result[:len(result)-1]
 	}
 	return result
@@ -40,7 +40,7 @@ func encodeForm(source map[string][]string, encoding encoding.Encoding) []byte {
 		}
 	}
 	result := buf.Bytes()
-	if result[len(result)-1] == '&' {
+	if len(result) > 0 && result[len(result)-1] == '&' {
 		result = result[:len(result)-1]
 	}
 	return result
@@ -0,0 +1,256 @@
+package httpclient
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+var (
+	urlType             = reflect.TypeOf(url.URL{})
+	durationType        = reflect.TypeOf(time.Duration(0))
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+	extractPatterns sync.Map // pattern -> *regexp.Regexp
+)
+
+// attributes holding urls, their values are resolved against the url of the response
+var urlAttributes = map[string]bool{"href": true, "src": true, "action": true, "formaction": true, "poster": true}
+
+// Extract fills the struct pointed to by v from the HTML of the response:
+//
+//	type Item struct {
+//		Title string    `css:"h2"`
+//		Link  *url.URL  `css:"a" attr:"href"`
+//		Price float64   `css:".price" re:"[\d.]+"`
+//		Body  string    `css:".body" mode:"html"`
+//		Date  time.Time `css:"time" attr:"datetime" layout:"2006-01-02"`
+//	}
+//	var page struct {
+//		Items []Item `css:"div.item"`
+//	}
+//
+// css selects the nodes within the enclosing node, the enclosing node itself if empty.
+// A slice gets a value from every node, other fields from the first one. Structs are
+// filled from within the selected node, untagged embedded structs from the enclosing node.
+// The value is the text of the node, its inner HTML with mode:"html", its outer HTML with
+// mode:"outerhtml" or an attribute with attr. re keeps the first submatch, or the whole match
+// if it has no group. href, src and action attributes and url.URL fields are resolved against URL().
+// Fields whose nodes, attribute or re match are missing keep their value.
+func (resp *HttpResponse) Extract(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return errors.New("httpclient: Extract needs a pointer to a struct")
+	}
+	doc, err := resp.HTMLDetectedEncode()
+	if err != nil {
+		return err
+	}
+	return extractStruct(doc.Selection, rv.Elem(), resp.url)
+}
+
+func extractStruct(sel *goquery.Selection, v reflect.Value, base *url.URL) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		selector, ok := field.Tag.Lookup("css")
+		if !ok {
+			if field.Anonymous && field.Type.Kind() == reflect.Struct {
+				if err := extractStruct(sel, v.Field(i), base); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		nodes := sel
+		if selector != "" {
+			nodes = sel.Find(selector)
+		}
+		if err := extractField(nodes, v.Field(i), field.Tag, base); err != nil {
+			return fmt.Errorf("httpclient: extract %s.%s: %v", t.Name(), field.Name, err)
+		}
+	}
+	return nil
+}
+
+func extractField(nodes *goquery.Selection, v reflect.Value, tag reflect.StructTag, base *url.URL) error {
+	if v.Kind() != reflect.Slice || v.Type().Elem().Kind() == reflect.Uint8 {
+		if nodes.Length() == 0 {
+			return nil
+		}
+		return extractValue(nodes.First(), v, tag, base)
+	}
+	slice := reflect.MakeSlice(v.Type(), 0, nodes.Length())
+	var err error
+	nodes.EachWithBreak(func(_ int, node *goquery.Selection) bool {
+		elem := reflect.New(v.Type().Elem()).Elem()
+		if err = extractValue(node, elem, tag, base); err != nil {
+			return false
+		}
+		slice = reflect.Append(slice, elem)
+		return true
+	})
+	v.Set(slice)
+	return err
+}
+
+func extractValue(node *goquery.Selection, v reflect.Value, tag reflect.StructTag, base *url.URL) error {
+	if v.Kind() == reflect.Ptr {
+		ptr := reflect.New(v.Type().Elem())
+		if err := extractValue(node, ptr.Elem(), tag, base); err != nil {
+			return err
+		}
+		v.Set(ptr)
+		return nil
+	}
+	if v.Kind() == reflect.Struct && v.Type() != timeType && v.Type() != urlType &&
+		!reflect.PtrTo(v.Type()).Implements(textUnmarshalerType) {
+		return extractStruct(node, v, base)
+	}
+	s, ok, err := nodeValue(node, tag, base)
+	if err != nil || !ok {
+		return err
+	}
+	return setExtracted(v, s, tag, base)
+}
+
+// nodeValue returns the string selected by the tag from node, ok is false if there is none.
+func nodeValue(node *goquery.Selection, tag reflect.StructTag, base *url.URL) (s string, ok bool, err error) {
+	if attr, isAttr := tag.Lookup("attr"); isAttr {
+		if s, ok = node.Attr(attr); !ok {
+			return "", false, nil
+		}
+		s = strings.TrimSpace(s)
+		if urlAttributes[strings.ToLower(attr)] && base != nil {
+			if u, err := base.Parse(s); err == nil {
+				s = u.String()
+			}
+		}
+	} else {
+		switch mode := tag.Get("mode"); mode {
+		case "", "text":
+			s = strings.TrimSpace(node.Text())
+		case "html":
+			if s, err = node.Html(); err != nil {
+				return "", false, err
+			}
+		case "outerhtml":
+			if s, err = goquery.OuterHtml(node); err != nil {
+				return "", false, err
+			}
+		default:
+			return "", false, fmt.Errorf("unknown mode %q", mode)
+		}
+	}
+	if pattern, isFiltered := tag.Lookup("re"); isFiltered {
+		re, err := extractPattern(pattern)
+		if err != nil {
+			return "", false, err
+		}
+		match := re.FindStringSubmatch(s)
+		switch {
+		case match == nil:
+			return "", false, nil
+		case len(match) > 1:
+			s = match[1]
+		default:
+			s = match[0]
+		}
+	}
+	return s, true, nil
+}
+
+func extractPattern(pattern string) (*regexp.Regexp, error) {
+	if re, ok := extractPatterns.Load(pattern); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	extractPatterns.Store(pattern, re)
+	return re, nil
+}
+
+func setExtracted(v reflect.Value, s string, tag reflect.StructTag, base *url.URL) error {
+	if reflect.PtrTo(v.Type()).Implements(textUnmarshalerType) && v.Type() != timeType {
+		return v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s))
+	}
+	switch v.Type() {
+	case timeType:
+		layout := tag.Get("layout")
+		if layout == "" {
+			layout = time.RFC3339
+		}
+		t, err := time.Parse(layout, strings.TrimSpace(s))
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(t))
+		return nil
+	case durationType:
+		d, err := time.ParseDuration(strings.TrimSpace(s))
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	case urlType:
+		u, err := url.Parse(strings.TrimSpace(s))
+		if err != nil {
+			return err
+		}
+		if base != nil {
+			u = base.ResolveReference(u)
+		}
+		v.Set(reflect.ValueOf(*u))
+		return nil
+	}
+	s = strings.TrimSpace(s)
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		// []byte
+		v.SetBytes([]byte(s))
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
@@ -0,0 +1,348 @@
+package httpclient
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var errNotFeed = errors.New("httpclient: not an RSS or Atom feed")
+
+// Feed is an RSS 2.0, RSS 1.0 or Atom feed.
+type Feed struct {
+	Format      string // rss, rdf or atom
+	Title       string
+	Link        string
+	Description string
+	Updated     time.Time
+	Items       []FeedItem
+}
+
+// FeedItem is an item of an RSS feed or an entry of an Atom feed.
+type FeedItem struct {
+	ID          string // guid or id, the link if there is none
+	Title       string
+	Link        string
+	Description string // description or summary
+	Content     string // content:encoded or content
+	Author      string
+	Categories  []string
+	Published   time.Time
+	Updated     time.Time
+	Enclosures  []Enclosure
+}
+
+// Enclosure is a file attached to an item.
+type Enclosure struct {
+	URL    string
+	Type   string
+	Length int64
+}
+
+type rssDocument struct {
+	Channel rssChannel `xml:"channel"`
+	// RSS 1.0 items are siblings of the channel
+	Items []rssItem `xml:"item"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Links         []string  `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate"`
+	PubDate       string    `xml:"pubDate"`
+	Date          string    `xml:"date"` // dc:date
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	About       string   `xml:"about,attr"` // rdf:about
+	GUID        string   `xml:"guid"`
+	Title       string   `xml:"title"`
+	Links       []string `xml:"link"`
+	Description string   `xml:"description"`
+	Encoded     string   `xml:"encoded"` // content:encoded
+	Author      string   `xml:"author"`
+	Creator     string   `xml:"creator"` // dc:creator
+	Categories  []string `xml:"category"`
+	Subjects    []string `xml:"subject"` // dc:subject
+	PubDate     string   `xml:"pubDate"`
+	Date        string   `xml:"date"` // dc:date
+	Enclosures  []struct {
+		URL    string `xml:"url,attr"`
+		Type   string `xml:"type,attr"`
+		Length string `xml:"length,attr"`
+	} `xml:"enclosure"`
+}
+
+type atomFeed struct {
+	Title    string      `xml:"title"`
+	Subtitle string      `xml:"subtitle"`
+	Links    []atomLink  `xml:"link"`
+	Updated  string      `xml:"updated"`
+	Entries  []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href   string `xml:"href,attr"`
+	Rel    string `xml:"rel,attr"`
+	Type   string `xml:"type,attr"`
+	Length string `xml:"length,attr"`
+}
+
+// atomText is text, HTML or XHTML, XHTML being the markup within the element.
+type atomText struct {
+	Type  string `xml:"type,attr"`
+	Text  string `xml:",chardata"`
+	Inner string `xml:",innerxml"`
+}
+
+func (t atomText) String() string {
+	if t.Type == "xhtml" {
+		return strings.TrimSpace(t.Inner)
+	}
+	return strings.TrimSpace(t.Text)
+}
+
+type atomEntry struct {
+	ID         string     `xml:"id"`
+	Title      string     `xml:"title"`
+	Links      []atomLink `xml:"link"`
+	Summary    atomText   `xml:"summary"`
+	Content    atomText   `xml:"content"`
+	Authors    []string   `xml:"author>name"`
+	Categories []struct {
+		Term string `xml:"term,attr"`
+	} `xml:"category"`
+	Published string `xml:"published"`
+	Updated   string `xml:"updated"`
+}
+
+// Feed parses an RSS 2.0, RSS 1.0 or Atom feed, in the charset detected as AutoDecode does.
+// Relative links are resolved against URL().
+func (resp *HttpResponse) Feed() (*Feed, error) {
+	if resp.err != nil {
+		return nil, resp.err
+	}
+	decoder, err := resp.xmlDecoder(resp.body)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, errNotFeed
+		}
+		root, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		var feed *Feed
+		switch strings.ToLower(root.Name.Local) {
+		case "rss", "rdf":
+			var doc rssDocument
+			if err := decoder.DecodeElement(&doc, &root); err != nil {
+				return nil, err
+			}
+			feed = doc.feed(strings.ToLower(root.Name.Local))
+		case "feed":
+			var doc atomFeed
+			if err := decoder.DecodeElement(&doc, &root); err != nil {
+				return nil, err
+			}
+			feed = doc.feed()
+		default:
+			return nil, errNotFeed
+		}
+		feed.resolve(resp.url)
+		return feed, nil
+	}
+}
+
+func (doc *rssDocument) feed(format string) *Feed {
+	channel := doc.Channel
+	feed := &Feed{
+		Format:      format,
+		Title:       strings.TrimSpace(channel.Title),
+		Link:        firstNonEmpty(channel.Links...),
+		Description: strings.TrimSpace(channel.Description),
+	}
+	feed.Updated, _ = parseFeedTime(firstNonEmpty(channel.LastBuildDate, channel.PubDate, channel.Date))
+	for _, item := range append(channel.Items, doc.Items...) {
+		feedItem := FeedItem{
+			Title:       strings.TrimSpace(item.Title),
+			Link:        firstNonEmpty(item.Links...),
+			Description: strings.TrimSpace(item.Description),
+			Content:     strings.TrimSpace(item.Encoded),
+			Author:      firstNonEmpty(item.Author, item.Creator),
+		}
+		feedItem.ID = firstNonEmpty(item.GUID, item.About, feedItem.Link)
+		for _, category := range append(item.Categories, item.Subjects...) {
+			if category = strings.TrimSpace(category); category != "" {
+				feedItem.Categories = append(feedItem.Categories, category)
+			}
+		}
+		feedItem.Published, _ = parseFeedTime(firstNonEmpty(item.PubDate, item.Date))
+		feedItem.Updated = feedItem.Published
+		for _, enclosure := range item.Enclosures {
+			length, _ := strconv.ParseInt(strings.TrimSpace(enclosure.Length), 10, 64)
+			feedItem.Enclosures = append(feedItem.Enclosures, Enclosure{URL: enclosure.URL, Type: enclosure.Type, Length: length})
+		}
+		feed.Items = append(feed.Items, feedItem)
+	}
+	return feed
+}
+
+func (doc *atomFeed) feed() *Feed {
+	feed := &Feed{
+		Format:      "atom",
+		Title:       strings.TrimSpace(doc.Title),
+		Link:        atomHref(doc.Links, "alternate"),
+		Description: strings.TrimSpace(doc.Subtitle),
+	}
+	feed.Updated, _ = parseFeedTime(doc.Updated)
+	for _, entry := range doc.Entries {
+		item := FeedItem{
+			Title:       strings.TrimSpace(entry.Title),
+			Link:        atomHref(entry.Links, "alternate"),
+			Description: entry.Summary.String(),
+			Content:     entry.Content.String(),
+			Author:      strings.Join(entry.Authors, ", "),
+		}
+		item.ID = firstNonEmpty(entry.ID, item.Link)
+		for _, category := range entry.Categories {
+			if category.Term != "" {
+				item.Categories = append(item.Categories, category.Term)
+			}
+		}
+		item.Updated, _ = parseFeedTime(entry.Updated)
+		if item.Published, _ = parseFeedTime(entry.Published); item.Published.IsZero() {
+			item.Published = item.Updated
+		}
+		for _, link := range entry.Links {
+			if link.Rel == "enclosure" {
+				length, _ := strconv.ParseInt(strings.TrimSpace(link.Length), 10, 64)
+				item.Enclosures = append(item.Enclosures, Enclosure{URL: link.Href, Type: link.Type, Length: length})
+			}
+		}
+		feed.Items = append(feed.Items, item)
+	}
+	return feed
+}
+
+// atomHref returns the link of the relation rel, a link without rel being an alternate one.
+func atomHref(links []atomLink, rel string) string {
+	for _, link := range links {
+		if link.Rel == rel || link.Rel == "" && rel == "alternate" {
+			return link.Href
+		}
+	}
+	return ""
+}
+
+func (feed *Feed) resolve(base *url.URL) {
+	if base == nil {
+		return
+	}
+	resolve := func(ref *string) {
+		if *ref == "" {
+			return
+		}
+		if u, err := base.Parse(*ref); err == nil {
+			*ref = u.String()
+		}
+	}
+	resolve(&feed.Link)
+	for i := range feed.Items {
+		resolve(&feed.Items[i].Link)
+		for j := range feed.Items[i].Enclosures {
+			resolve(&feed.Items[i].Enclosures[j].URL)
+		}
+	}
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value = strings.TrimSpace(value); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// the date formats of feeds and sitemaps, RFC 822 and variations of it for RSS, W3C datetimes for the others
+var feedTimeLayouts = []string{
+	time.RFC3339,
+	time.RFC1123Z,
+	time.RFC1123,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"Mon, 2 Jan 2006 15:04 -0700",
+	"2 Jan 2006 15:04:05 -0700",
+	"2 Jan 2006 15:04:05 MST",
+	time.RFC822Z,
+	time.RFC822,
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02",
+	"2006-01",
+	"2006",
+}
+
+func parseFeedTime(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	var err error
+	for _, layout := range feedTimeLayouts {
+		var t time.Time
+		if t, err = time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, err
+}
+
+// Poller polls a feed, a sitemap or any other resource, sending the ETag and Last-Modified
+// of the last response so unchanged resources are not fetched again. Its fields can be saved
+// to poll on from another process. It is not safe for concurrent use.
+type Poller struct {
+	ETag         string
+	LastModified string
+	// Digest is the SHA-256 of the last body, to tell unchanged ones from servers ignoring the validators.
+	Digest string
+}
+
+// Poll sends req with the validators of the last response. changed is false if the resource
+// did not change, resp is then nil.
+func (p *Poller) Poll(req *HttpRequest) (resp *HttpResponse, changed bool, err error) {
+	if p.ETag != "" {
+		req.Head("If-None-Match", p.ETag)
+	}
+	if p.LastModified != "" {
+		req.Head("If-Modified-Since", p.LastModified)
+	}
+	resp = req.Send()
+	code, err := resp.Code()
+	if err != nil {
+		return nil, false, err
+	}
+	if code == http.StatusNotModified {
+		return nil, false, nil
+	}
+	if err := resp.CheckStatus(); err != nil {
+		return nil, false, err
+	}
+	sum := sha256.Sum256(resp.body)
+	digest := hex.EncodeToString(sum[:])
+	changed = digest != p.Digest
+	p.ETag, p.LastModified, p.Digest = resp.header.Get("ETag"), resp.header.Get("Last-Modified"), digest
+	if !changed {
+		return nil, false, nil
+	}
+	return resp, true, nil
+}
@@ -0,0 +1,274 @@
+package httpclient
+
+import (
+	"bytes"
+	"errors"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/text/encoding"
+)
+
+var errFormAction = errors.New("httpclient: form has no action url")
+
+const (
+	formURLEncoded = "application/x-www-form-urlencoded"
+	formMultipart  = "multipart/form-data"
+)
+
+// Form is an HTML form found by HttpResponse.Forms.
+type Form struct {
+	Name    string
+	ID      string
+	Action  *url.URL // resolved against the page
+	Method  string   // GET or POST
+	Enctype string   // application/x-www-form-urlencoded or multipart/form-data
+	Fields  []FormField
+
+	page     *url.URL
+	request  *HttpRequest
+	encoding encoding.Encoding
+	files    []formFile
+}
+
+// FormField is a successful control of a form, as a browser would submit it.
+type FormField struct {
+	Name    string
+	Value   string
+	Type    string   // type of an input, or select or textarea
+	Options []string // values of the options of a select
+}
+
+type formFile struct {
+	field, filename string
+	content         []byte
+}
+
+// Forms returns the forms of the HTML page. Submitting them continues the session of the
+// request that fetched the page and encodes the values in the charset of the page.
+func (resp *HttpResponse) Forms() ([]*Form, error) {
+	doc, err := resp.HTMLDetectedEncode()
+	if err != nil {
+		return nil, err
+	}
+	base := documentBase(doc, resp.url)
+	var forms []*Form
+	doc.Find("form").Each(func(_ int, sel *goquery.Selection) {
+		forms = append(forms, parseForm(sel, base, resp))
+	})
+	return forms, nil
+}
+
+func parseForm(sel *goquery.Selection, base *url.URL, resp *HttpResponse) *Form {
+	form := &Form{
+		Name:     sel.AttrOr("name", ""),
+		ID:       sel.AttrOr("id", ""),
+		Method:   http.MethodGet,
+		Enctype:  formURLEncoded,
+		page:     resp.url,
+		request:  resp.request,
+		encoding: resp.bodyEncoding(),
+	}
+	if strings.EqualFold(sel.AttrOr("method", ""), http.MethodPost) {
+		form.Method = http.MethodPost
+	}
+	if strings.EqualFold(sel.AttrOr("enctype", ""), formMultipart) {
+		form.Enctype = formMultipart
+	}
+	form.Action = resp.url
+	if base != nil {
+		if action, err := base.Parse(sel.AttrOr("action", "")); err == nil {
+			form.Action = action
+		}
+	}
+	if form.Action != nil {
+		// the fragment is not sent, an empty action submits to the page itself
+		action := *form.Action
+		action.Fragment = ""
+		form.Action = &action
+	}
+
+	sel.Find("input, select, textarea").Each(func(_ int, control *goquery.Selection) {
+		name, ok := control.Attr("name")
+		if !ok || name == "" {
+			return
+		}
+		if _, disabled := control.Attr("disabled"); disabled {
+			return
+		}
+		switch goquery.NodeName(control) {
+		case "textarea":
+			form.Fields = append(form.Fields, FormField{Name: name, Value: control.Text(), Type: "textarea"})
+		case "select":
+			field := FormField{Name: name, Type: "select"}
+			selected := false
+			control.Find("option").Each(func(i int, option *goquery.Selection) {
+				value, ok := option.Attr("value")
+				if !ok {
+					value = strings.TrimSpace(option.Text())
+				}
+				field.Options = append(field.Options, value)
+				// the first selected option, or the first option if none is
+				_, isSelected := option.Attr("selected")
+				if i == 0 || isSelected && !selected {
+					field.Value = value
+					selected = isSelected
+				}
+			})
+			form.Fields = append(form.Fields, field)
+		default:
+			inputType := strings.ToLower(control.AttrOr("type", "text"))
+			switch inputType {
+			case "submit", "button", "image", "reset", "file":
+				return
+			case "checkbox", "radio":
+				if _, checked := control.Attr("checked"); !checked {
+					return
+				}
+				form.Fields = append(form.Fields, FormField{Name: name, Value: control.AttrOr("value", "on"), Type: inputType})
+			default:
+				form.Fields = append(form.Fields, FormField{Name: name, Value: control.AttrOr("value", ""), Type: inputType})
+			}
+		}
+	})
+	return form
+}
+
+// Get returns the value of the first field name.
+func (f *Form) Get(name string) string {
+	for _, field := range f.Fields {
+		if field.Name == name {
+			return field.Value
+		}
+	}
+	return ""
+}
+
+// Set sets the value of the field name, replacing all its values, and adds it if it is missing.
+func (f *Form) Set(name, value string) *Form {
+	for i := range f.Fields {
+		if f.Fields[i].Name == name {
+			f.Fields[i].Value = value
+			f.del(name, i+1)
+			return f
+		}
+	}
+	return f.Add(name, value)
+}
+
+// Add adds a value to the field name.
+func (f *Form) Add(name, value string) *Form {
+	f.Fields = append(f.Fields, FormField{Name: name, Value: value, Type: "hidden"})
+	return f
+}
+
+// Del removes the field name.
+func (f *Form) Del(name string) *Form {
+	f.del(name, 0)
+	return f
+}
+
+func (f *Form) del(name string, from int) {
+	fields := f.Fields[:from]
+	for _, field := range f.Fields[from:] {
+		if field.Name != name {
+			fields = append(fields, field)
+		}
+	}
+	f.Fields = fields
+}
+
+// SetFile attaches a file to the field name, the form is then submitted as multipart/form-data.
+func (f *Form) SetFile(name, filename string, content []byte) *Form {
+	f.files = append(f.files, formFile{field: name, filename: filename, content: content})
+	f.Enctype = formMultipart
+	f.Method = http.MethodPost
+	return f
+}
+
+// Values returns the values the form submits.
+func (f *Form) Values() url.Values {
+	values := url.Values{}
+	for _, field := range f.Fields {
+		values.Add(field.Name, field.Value)
+	}
+	return values
+}
+
+// Submit builds the request submitting the form, in the session of the page.
+func (f *Form) Submit() *HttpRequest {
+	var req *HttpRequest
+	if f.request != nil {
+		action := ""
+		if f.Action != nil {
+			action = f.Action.String()
+		}
+		req = f.request.derive(f.page, action)
+	} else {
+		req = NewHttpRequest(http.DefaultClient)
+	}
+	req.encoding = f.encoding
+	if f.page != nil {
+		req.RefererInHeader(f.page.String())
+	}
+	if f.Action == nil {
+		req.err = errFormAction
+		return req
+	}
+
+	if f.Method == http.MethodGet {
+		// the query of the action is replaced by the fields, as browsers do
+		action := *f.Action
+		action.RawQuery = ""
+		req.Url(action.String()).Method(http.MethodGet)
+		for _, field := range f.Fields {
+			req.Query(field.Name, field.Value)
+		}
+		return req
+	}
+	req.Url(f.Action.String()).Method(http.MethodPost)
+	if f.Enctype != formMultipart {
+		for _, field := range f.Fields {
+			if req.params == nil {
+				req.Param(field.Name, field.Value)
+			} else {
+				req.params[field.Name] = append(req.params[field.Name], field.Value)
+			}
+		}
+		if req.params == nil {
+			req.Head("Content-Type", formURLEncoded)
+		}
+		return req
+	}
+
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+	for _, field := range f.Fields {
+		value := field.Value
+		if f.encoding != nil {
+			value, _ = f.encoding.NewEncoder().String(value)
+		}
+		if err := writer.WriteField(field.Name, value); err != nil {
+			req.err = err
+			return req
+		}
+	}
+	for _, file := range f.files {
+		part, err := writer.CreateFormFile(file.field, file.filename)
+		if err == nil {
+			_, err = part.Write(file.content)
+		}
+		if err != nil {
+			req.err = err
+			return req
+		}
+	}
+	if err := writer.Close(); err != nil {
+		req.err = err
+		return req
+	}
+	return req.Head("Content-Type", writer.FormDataContentType()).Body(buf.Bytes())
+}
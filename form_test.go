@@ -0,0 +1,55 @@
+package httpclient
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestFormSubmitCredentials(t *testing.T) {
+	var mu sync.Mutex
+	received := map[string]http.Header{}
+	record := func(name string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			received[name] = r.Header.Clone()
+			mu.Unlock()
+		}
+	}
+	other := httptest.NewServer(record("other origin"))
+	defer other.Close()
+	// localhost is another host than the 127.0.0.1 of the page, cookies are not shared by port
+	otherURL := strings.Replace(other.URL, "127.0.0.1", "localhost", 1)
+	page := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/submit" {
+			record("same origin")(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<form method="post" action="/submit"><input name="q" value="1"></form>` +
+			`<form method="post" action="` + otherURL + `/submit"><input name="q" value="1"></form>`))
+	}))
+	defer page.Close()
+
+	resp := New(&http.Client{}).Get(page.URL).BasicAuth("user", "password").Cookies("session=secret").
+		APIKey("X-Api-Key", "key").Send()
+	forms, err := resp.Forms()
+	if err != nil || len(forms) != 2 {
+		t.Fatalf("forms %v, %v, want 2", forms, err)
+	}
+	for _, form := range forms {
+		if err := form.Submit().Send().CheckStatus(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for name, credentials := range map[string]bool{"same origin": true, "other origin": false} {
+		header := received[name]
+		for _, key := range []string{"Authorization", "Cookie", "X-Api-Key"} {
+			if sent := header.Get(key) != ""; sent != credentials {
+				t.Errorf("%s: %s sent %t, want %t", name, key, sent, credentials)
+			}
+		}
+	}
+}
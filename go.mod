@@ -4,7 +4,10 @@ go 1.13
 
 require (
 	github.com/PuerkitoBio/goquery v1.5.1
+	github.com/antchfx/xpath v1.1.10
 	github.com/cocotyty/cookiejar v0.0.0-20151117100550-02df9891c5cb
-	golang.org/x/net v0.0.0-20200219183655-46282727080f
-	golang.org/x/text v0.3.2
+	github.com/saintfish/chardet v0.0.0-20230101081208-5e3ef4b5456d
+	golang.org/x/net v0.0.0-20210226172049-e18ecbb05110
+	golang.org/x/text v0.3.3
+	gopkg.in/yaml.v2 v2.3.0
 )
@@ -0,0 +1,254 @@
+package httpclient
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cocotyty/cookiejar"
+	"golang.org/x/net/publicsuffix"
+)
+
+// DefaultHedgeStats records hedged requests that have no HedgeStats of their own.
+var DefaultHedgeStats = &HedgeStats{}
+
+// HedgeStats counts hedged requests, it is safe for concurrent use.
+type HedgeStats struct {
+	requests int64
+	hedges   int64
+	wins     int64
+}
+
+// Requests returns the number of requests sent with Hedge.
+func (s *HedgeStats) Requests() int64 { return atomic.LoadInt64(&s.requests) }
+
+// Hedges returns the number of extra attempts launched.
+func (s *HedgeStats) Hedges() int64 { return atomic.LoadInt64(&s.hedges) }
+
+// Wins returns the number of requests answered by an extra attempt instead of the first one.
+func (s *HedgeStats) Wins() int64 { return atomic.LoadInt64(&s.wins) }
+
+// Hedge sends up to maxExtra duplicate attempts, one each time the previous attempts have not
+// answered within delay or have failed. The first successful response wins and the other attempts
+// are canceled, the cookies they received are dropped. Only GET, HEAD and OPTIONS requests are
+// hedged unless HedgeAnyMethod is set, other requests are sent once.
+func (req *HttpRequest) Hedge(delay time.Duration, maxExtra int) *HttpRequest {
+	req.hedgeDelay = delay
+	req.hedgeExtra = maxExtra
+	return req
+}
+
+// HedgeAnyMethod lets Hedge duplicate the request whatever its method,
+// for servers known to handle it idempotently.
+func (req *HttpRequest) HedgeAnyMethod() *HttpRequest {
+	req.hedgeAny = true
+	return req
+}
+
+// HedgeStats records the hedging of the request in s instead of DefaultHedgeStats.
+func (req *HttpRequest) HedgeStats(s *HedgeStats) *HttpRequest {
+	req.hedgeStats = s
+	return req
+}
+
+// Clone returns a copy of the request that can be changed and sent independently.
+// The client, cookie store and authenticators are shared with req.
+func (req *HttpRequest) Clone() *HttpRequest {
+	clone := *req
+	clone.header = req.header.Clone()
+	clone.querys = make([][]string, len(req.querys))
+	for i, kv := range req.querys {
+		clone.querys[i] = append([]string(nil), kv...)
+	}
+	if req.params != nil {
+		clone.params = make(map[string][]string, len(req.params))
+		for k, vs := range req.params {
+			clone.params[k] = append([]string(nil), vs...)
+		}
+	}
+	if req.pathParams != nil {
+		clone.pathParams = make(map[string]string, len(req.pathParams))
+		for k, v := range req.pathParams {
+			clone.pathParams[k] = v
+		}
+	}
+	clone.cookies = append([]*http.Cookie(nil), req.cookies...)
+	clone.apiKeys = append([]string(nil), req.apiKeys...)
+	return &clone
+}
+
+// derive returns a GET of rawURL within the session of req, without the url, query and body of req.
+// As on a redirect, the credentials and the Host override are dropped if rawURL is not on the
+// origin of from, the page it was found on.
+func (req *HttpRequest) derive(from *url.URL, rawURL string) *HttpRequest {
+	derived := req.Clone()
+	derived.baseURL, derived.path, derived.pathParams = "", "", nil
+	derived.querys, derived.params, derived.body, derived.jsonData = nil, nil, nil, nil
+	derived.htmlRedirects, derived.redirectOrigin, derived.redirectHops = 0, nil, 0
+	derived.header.Del("Content-Type")
+	if to, err := url.Parse(rawURL); from == nil || err != nil || !sameOrigin(from, to) {
+		derived.host = ""
+		derived.dropCredentials()
+	}
+	return derived.Method(http.MethodGet).Url(rawURL)
+}
+
+func safeMethod(method string) bool {
+	switch method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	return false
+}
+
+type hedgeResult struct {
+	attempt int
+	resp    *HttpResponse
+	jar     *hedgeJar
+}
+
+// hedgeJar keeps the cookies set during an attempt apart from the session until the attempt wins.
+type hedgeJar struct {
+	session http.CookieJar
+	jar     http.CookieJar // the cookies of the attempt
+
+	mu  sync.Mutex
+	set []hedgeCookies
+}
+
+type hedgeCookies struct {
+	u       *url.URL
+	cookies []*http.Cookie
+}
+
+func newHedgeJar(session http.CookieJar) *hedgeJar {
+	jar, _ := cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
+	return &hedgeJar{session: session, jar: jar}
+}
+
+func (j *hedgeJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.jar.SetCookies(u, cookies)
+	j.set = append(j.set, hedgeCookies{u: u, cookies: cookies})
+}
+
+// Cookies returns the cookies of the session for u, replaced by those of the attempt with the same name.
+func (j *hedgeJar) Cookies(u *url.URL) []*http.Cookie {
+	j.mu.Lock()
+	own := j.jar.Cookies(u)
+	j.mu.Unlock()
+	names := make(map[string]bool, len(own))
+	for _, c := range own {
+		names[c.Name] = true
+	}
+	for _, c := range j.session.Cookies(u) {
+		if !names[c.Name] {
+			own = append(own, c)
+		}
+	}
+	return own
+}
+
+// commit sets the cookies of the attempt in the session.
+func (j *hedgeJar) commit() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for _, set := range j.set {
+		j.session.SetCookies(set.u, set.cookies)
+	}
+	j.set = nil
+}
+
+func (resp *HttpResponse) successful() bool {
+	return resp.err == nil && resp.code < http.StatusInternalServerError
+}
+
+func (req *HttpRequest) hedge(target string, body []byte) *HttpResponse {
+	stats := req.hedgeStats
+	if stats == nil {
+		stats = DefaultHedgeStats
+	}
+	atomic.AddInt64(&stats.requests, 1)
+	parent := req.ctx
+	if parent == nil {
+		parent = context.Background()
+	}
+
+	results := make(chan hedgeResult, req.hedgeExtra+1)
+	var cancels []context.CancelFunc
+	defer func() {
+		for _, cancel := range cancels {
+			cancel()
+		}
+	}()
+	session := req.cookieJar()
+	launched := 0
+	launch := func() {
+		attempt := req.Clone()
+		// the session is updated by the attempt answered only
+		jar := newHedgeJar(session)
+		client := *req.client
+		client.Jar = jar
+		attempt.client, attempt.storeCookie = &client, nil
+		var cancelAttempt context.CancelFunc
+		attempt.ctx, cancelAttempt = context.WithCancel(parent)
+		cancels = append(cancels, cancelAttempt)
+		if launched > 0 {
+			// dumps are written by the first attempt only
+			attempt.dumpRequest, attempt.dumpResponse = nil, nil
+			atomic.AddInt64(&stats.hedges, 1)
+		}
+		n := launched
+		launched++
+		go func() {
+			results <- hedgeResult{attempt: n, resp: attempt.exchange(target, body), jar: jar}
+		}()
+	}
+	canHedge := func() bool {
+		return launched <= req.hedgeExtra && parent.Err() == nil
+	}
+
+	launch()
+	timer := time.NewTimer(req.hedgeDelay)
+	defer timer.Stop()
+	var failed hedgeResult
+	for pending := 1; pending > 0; {
+		select {
+		case <-timer.C:
+			if canHedge() {
+				launch()
+				pending++
+				timer.Reset(req.hedgeDelay)
+			}
+		case r := <-results:
+			pending--
+			if r.resp.successful() {
+				if r.attempt > 0 {
+					atomic.AddInt64(&stats.wins, 1)
+				}
+				return req.settle(r)
+			}
+			if failed.resp == nil {
+				failed = r
+			}
+			if canHedge() {
+				launch()
+				pending++
+			}
+		}
+	}
+	return req.settle(failed)
+}
+
+// settle keeps the cookies of the attempt answered in the session.
+func (req *HttpRequest) settle(r hedgeResult) *HttpResponse {
+	r.jar.commit()
+	if req.storeCookie != nil {
+		req.storeCookie(req.sessionID, req.client.Jar)
+	}
+	return r.resp
+}
@@ -0,0 +1,83 @@
+package httpclient
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cocotyty/cookiejar"
+	"golang.org/x/net/publicsuffix"
+)
+
+// slowFirst answers the first request after a while, with the cookie first, and the others at once with the cookie hedge.
+func slowFirst(requests *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(requests, 1) == 1 {
+			http.SetCookie(w, &http.Cookie{Name: "first", Value: "1"})
+			w.WriteHeader(http.StatusOK)
+			w.(http.Flusher).Flush()
+			time.Sleep(200 * time.Millisecond)
+			w.Write([]byte("first"))
+			return
+		}
+		http.SetCookie(w, &http.Cookie{Name: "hedge", Value: "1"})
+		w.Write([]byte("hedge"))
+	}))
+}
+
+func TestHedgeKeepsTheCookiesOfTheWinner(t *testing.T) {
+	var requests int32
+	server := slowFirst(&requests)
+	defer server.Close()
+
+	jar, _ := cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
+	stored := 0
+	req := NewHttpRequest(&http.Client{Jar: jar}).Url(server.URL).Hedge(20*time.Millisecond, 1).
+		HedgeStats(&HedgeStats{}).SetCookieStore(func(string, http.CookieJar) { stored++ })
+	body, err := req.Send().String()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body != "hedge" {
+		t.Fatalf("body = %q, want the answer of the hedge", body)
+	}
+	time.Sleep(250 * time.Millisecond)
+	u, _ := url.Parse(server.URL)
+	cookies := jar.Cookies(u)
+	if len(cookies) != 1 || cookies[0].Name != "hedge" {
+		t.Errorf("session cookies %v, want only those of the hedge", cookies)
+	}
+	if stored != 1 {
+		t.Errorf("cookies stored %d times, want once", stored)
+	}
+}
+
+func TestHedgeMethods(t *testing.T) {
+	for _, test := range []struct {
+		method   string
+		any      bool
+		requests int32
+	}{
+		{http.MethodGet, false, 2},
+		{http.MethodPut, false, 1},
+		{http.MethodPost, false, 1},
+		{http.MethodPost, true, 2},
+	} {
+		var requests int32
+		server := slowFirst(&requests)
+		req := NewHttpRequest(&http.Client{}).Method(test.method).Url(server.URL).Hedge(20*time.Millisecond, 1).HedgeStats(&HedgeStats{})
+		if test.any {
+			req.HedgeAnyMethod()
+		}
+		if err := req.Send().CheckStatus(); err != nil {
+			t.Fatal(err)
+		}
+		server.Close()
+		if got := atomic.LoadInt32(&requests); got != test.requests {
+			t.Errorf("%s (any method %t): %d requests, want %d", test.method, test.any, got, test.requests)
+		}
+	}
+}
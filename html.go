@@ -2,70 +2,114 @@ package httpclient
 
 import (
 	"bytes"
+	"net/http"
 	"regexp"
+	"strings"
 
 	"github.com/PuerkitoBio/goquery"
-	"golang.org/x/text/encoding/htmlindex"
 )
 
+// HTML parses the body decoded with the encoding of the response, detected as AutoDecode does if it is not set.
 func (resp *HttpResponse) HTML() (doc *goquery.Document, err error) {
 	if resp.err != nil {
 		return nil, resp.err
 	}
-	enc := resp.encoding
-	data := resp.body
-	if enc != nil {
-		data, err = enc.NewDecoder().Bytes(resp.body)
-		if err != nil {
-			return nil, err
-		}
+	data, err := resp.decodedBody()
+	if err != nil {
+		return nil, err
 	}
 	return goquery.NewDocumentFromReader(bytes.NewReader(data))
 }
 
-var contentTypeMatchCharset = regexp.MustCompile(`\bcharset=([\w|\-]*)`)
-
+// HTMLDetectedEncode is HTML, which detects the charset itself.
 func (resp *HttpResponse) HTMLDetectedEncode() (doc *goquery.Document, err error) {
-	if resp.err != nil {
-		return nil, resp.err
+	return resp.HTML()
+}
+
+// FollowHTMLRedirects follows up to maxHops redirects of HTML pages made by
+// <meta http-equiv="refresh"> or a script assigning window.location, within the same session.
+// They are recorded in the History of the response like HTTP redirects.
+func (req *HttpRequest) FollowHTMLRedirects(maxHops int) *HttpRequest {
+	req.htmlRedirects = maxHops
+	return req
+}
+
+var (
+	refreshURLPrefix = regexp.MustCompile(`(?i)^\s*url\s*=\s*`)
+	// scriptLocation matches redirects written as top-level statements, location = "...",
+	// location.href = "..." or location.replace("...") on the page or its window. The location
+	// properties of other objects do not match, statements in functions or conditions still do.
+	scriptLocation = regexp.MustCompile(`(?m)(?:^|[^\w.$])(?:(?:window|document|self|top)\.)?location` +
+		`(?:(?:\.href)?\s*=\s*["']([^"']+)["']|\.(?:replace|assign)\(\s*["']([^"']+)["']\s*\))`)
+)
+
+// htmlRedirect returns the target of a meta refresh or script redirect of an HTML page.
+func (resp *HttpResponse) htmlRedirect() (string, RedirectKind, bool) {
+	if resp.err != nil || resp.code < 200 || resp.code > 299 ||
+		!strings.Contains(http.DetectContentType(resp.body), "html") && !strings.Contains(resp.header.Get("Content-Type"), "html") {
+		return "", 0, false
 	}
-	enc := resp.encoding
-	if enc == nil {
-		doc, err := goquery.NewDocumentFromReader(bytes.NewReader(resp.body))
-		if err != nil {
-			return nil, err
-		}
-		encName := ""
-		selector := doc.Find("meta[charset]")
-		if selector != nil && selector.Size() > 0 {
-			encName, _ = selector.Attr("charset")
-		} else {
-			selector = doc.Find(`meta[http-equiv="Content-Type"]`)
-			if selector != nil && selector.Size() > 0 {
-				attrContent, exists := selector.Attr("content")
-				if exists {
-					subMatch := contentTypeMatchCharset.FindStringSubmatch(attrContent)
-					if len(subMatch) == 2 {
-						encName = subMatch[1]
-					}
-				}
-			}
+	doc, err := resp.HTMLDetectedEncode()
+	if err != nil {
+		return "", 0, false
+	}
+	var target string
+	doc.Find("meta[http-equiv]").EachWithBreak(func(_ int, meta *goquery.Selection) bool {
+		if equiv, _ := meta.Attr("http-equiv"); !strings.EqualFold(equiv, "refresh") {
+			return true
 		}
-		if encName != "" {
-			enc, err = htmlindex.Get(encName)
-			if err != nil {
-				return nil, err
-			}
+		content, _ := meta.Attr("content")
+		// content is "<delay>; url=<target>", a delay alone reloads the page
+		if i := strings.IndexAny(content, ";,"); i >= 0 {
+			target = strings.Trim(refreshURLPrefix.ReplaceAllString(content[i+1:], ""), ` "'`)
 		}
+		return target == ""
+	})
+	if target != "" {
+		return target, MetaRefresh, true
 	}
+	doc.Find("script").EachWithBreak(func(_ int, script *goquery.Selection) bool {
+		if match := scriptLocation.FindStringSubmatch(script.Text()); match != nil {
+			target = match[1] + match[2]
+		}
+		return target == ""
+	})
+	return target, JavaScriptRedirect, target != ""
+}
 
-	data := resp.body
+func (req *HttpRequest) followHTMLRedirects(resp *HttpResponse) *HttpResponse {
+	history := resp.history
+	origin := resp.url
+	if len(history) > 0 {
+		origin = history[0].URL
+	}
+	hop := req
+	for hops := 0; hops < req.htmlRedirects; hops++ {
+		location, kind, ok := resp.htmlRedirect()
+		if !ok {
+			break
+		}
+		to, err := resp.url.Parse(location)
+		if err != nil || to.String() == resp.url.String() || to.Scheme != "http" && to.Scheme != "https" {
+			break
+		}
+		redirect := Redirect{Kind: kind, StatusCode: resp.code, URL: resp.url, Location: to, Header: resp.header,
+			Cookies: (&http.Response{Header: resp.header}).Cookies()}
+		history = append(history, redirect)
 
-	if enc != nil {
-		data, err = enc.NewDecoder().Bytes(resp.body)
-		if err != nil {
-			return nil, err
+		// a hop like those of HTTP redirects, the ones it is answered with count against the same policy
+		next := hop.Clone()
+		next.method = http.MethodGet
+		next.header.Del("Content-Type")
+		next.prepareHop(resp.url, to, req.redirectPolicy != nil && req.redirectPolicy.KeepCredentials)
+		next.redirectOrigin, next.redirectHops = origin, len(history)
+		if err := next.checkHop(origin, resp.url, to, len(history)); err != nil {
+			return &HttpResponse{err: err, history: history}
 		}
+		hop = next
+		resp = hop.exchange(to.String(), nil)
+		history = append(history, resp.history...)
 	}
-	return goquery.NewDocumentFromReader(bytes.NewReader(data))
+	resp.history = history
+	return resp
 }
@@ -0,0 +1,61 @@
+package httpclient
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHTMLRedirects(t *testing.T) {
+	meta := func(to string) string {
+		return `<html><head><meta http-equiv="refresh" content="0; url=` + to + `"></head></html>`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/robots.txt":
+			w.Write([]byte("User-agent: *\nDisallow: /private\n"))
+		case "/a":
+			w.Write([]byte(meta("/b")))
+		case "/b":
+			http.Redirect(w, r, "/c", http.StatusFound)
+		case "/c":
+			w.Write([]byte(meta("/d")))
+		case "/to-private-redirect":
+			http.Redirect(w, r, "/private", http.StatusFound)
+		case "/to-private-meta":
+			w.Write([]byte(meta("/private")))
+		default:
+			w.Write([]byte(r.URL.Path))
+		}
+	}))
+	defer server.Close()
+
+	for _, test := range []struct {
+		path    string
+		maxHops int
+		body    string
+		hops    int
+		err     bool
+	}{
+		{"/a", 3, "/d", 3, false},
+		// the HTTP redirect between the two HTML ones counts against MaxHops, the refused one is recorded
+		{"/a", 2, "", 3, true},
+	} {
+		resp := New(&http.Client{}).Redirects(&RedirectPolicy{MaxHops: test.maxHops}).Get(server.URL + test.path).
+			FollowHTMLRedirects(5).Send()
+		body, err := resp.String()
+		if _, ok := err.(*RedirectError); ok != test.err || body != test.body || len(resp.History()) != test.hops {
+			t.Errorf("%s with %d hops: body %q, err %v after %d redirects, want %q after %d redirects",
+				test.path, test.maxHops, body, err, len(resp.History()), test.body, test.hops)
+		}
+	}
+
+	robots := &Robots{}
+	for _, path := range []string{"/to-private-redirect", "/to-private-meta"} {
+		err := New(&http.Client{}).Redirects(&RedirectPolicy{}).Robots(robots).Get(server.URL + path).
+			FollowHTMLRedirects(5).Send().CheckStatus()
+		if _, ok := err.(*RobotsError); !ok {
+			t.Errorf("%s: err = %v, want a RobotsError for the disallowed target", path, err)
+		}
+	}
+}
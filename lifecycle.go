@@ -0,0 +1,83 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrShutdown is returned by requests of a Builder that has been shut down.
+var ErrShutdown = errors.New("httpclient: builder is shut down")
+
+// CacheFlusher is implemented by caches writing behind, Builder.Shutdown flushes them.
+type CacheFlusher interface {
+	Flush(ctx context.Context) error
+}
+
+// tracker keeps the requests in flight so they can be drained or canceled.
+type tracker struct {
+	mu     sync.Mutex
+	closed bool
+	wg     sync.WaitGroup
+	active map[*HttpRequest]trackedRequest
+}
+
+type trackedRequest struct {
+	parent context.Context
+	cancel context.CancelFunc
+}
+
+// begin registers req and binds it to a context canceled by shutdown.
+func (t *tracker) begin(req *HttpRequest) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return ErrShutdown
+	}
+	if t.active == nil {
+		t.active = make(map[*HttpRequest]trackedRequest)
+	}
+	parent := req.ctx
+	if parent == nil {
+		parent = context.Background()
+	}
+	ctx, cancel := context.WithCancel(parent)
+	t.active[req] = trackedRequest{parent: req.ctx, cancel: cancel}
+	req.ctx = ctx
+	t.wg.Add(1)
+	return nil
+}
+
+func (t *tracker) end(req *HttpRequest) {
+	t.mu.Lock()
+	tracked := t.active[req]
+	delete(t.active, req)
+	t.mu.Unlock()
+	tracked.cancel()
+	req.ctx = tracked.parent
+	t.wg.Done()
+}
+
+// shutdown rejects new requests and waits for the active ones, they are canceled once ctx is done.
+func (t *tracker) shutdown(ctx context.Context) error {
+	t.mu.Lock()
+	t.closed = true
+	t.mu.Unlock()
+	drained := make(chan struct{})
+	go func() {
+		t.wg.Wait()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+	}
+	t.mu.Lock()
+	for _, tracked := range t.active {
+		tracked.cancel()
+	}
+	t.mu.Unlock()
+	<-drained
+	return ctx.Err()
+}
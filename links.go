@@ -0,0 +1,192 @@
+package httpclient
+
+import (
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/idna"
+)
+
+// TrackingParams are the query params Canonicalize removes, a trailing * matches a prefix.
+var TrackingParams = []string{
+	"utm_*", "fbclid", "gclid", "dclid", "gbraid", "wbraid", "msclkid", "yclid",
+	"mc_cid", "mc_eid", "igshid", "_ga", "_gl", "_hsenc", "_hsmi", "mkt_tok",
+}
+
+var defaultPorts = map[string]string{"http": "80", "https": "443", "ws": "80", "wss": "443", "ftp": "21"}
+
+// the attributes links are read from, by selector
+var linkAttributes = []struct{ selector, attr string }{
+	{"a[href]", "href"},
+	{"area[href]", "href"},
+	{"link[href]", "href"},
+	{"img[src]", "src"},
+	{"script[src]", "src"},
+	{"img[srcset]", "srcset"},
+	{"source[srcset]", "srcset"},
+}
+
+var linkSelector = func() string {
+	selectors := make([]string, len(linkAttributes))
+	for i, link := range linkAttributes {
+		selectors[i] = link.selector
+	}
+	return strings.Join(selectors, ", ")
+}()
+
+// Links returns the http and https urls the HTML page links to, resolved against its <base href>
+// or the final URL after redirects, in document order without duplicates. They are read from
+// a[href], area[href], link[href], img[src], script[src] and srcset.
+func (resp *HttpResponse) Links() ([]*url.URL, error) {
+	doc, err := resp.HTML()
+	if err != nil {
+		return nil, err
+	}
+	base := documentBase(doc, resp.url)
+	if base == nil {
+		base = &url.URL{}
+	}
+	seen := map[string]bool{}
+	var links []*url.URL
+	add := func(ref string) {
+		ref = strings.TrimSpace(ref)
+		if ref == "" {
+			return
+		}
+		u, err := base.Parse(ref)
+		if err != nil || u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+			return
+		}
+		if s := u.String(); !seen[s] {
+			seen[s] = true
+			links = append(links, u)
+		}
+	}
+	doc.Find(linkSelector).Each(func(_ int, sel *goquery.Selection) {
+		for _, link := range linkAttributes {
+			if !sel.Is(link.selector) {
+				continue
+			}
+			value := sel.AttrOr(link.attr, "")
+			if link.attr != "srcset" {
+				add(value)
+				continue
+			}
+			// candidates are separated by commas, each an url with an optional descriptor
+			for _, candidate := range strings.Split(value, ",") {
+				if fields := strings.Fields(candidate); len(fields) > 0 {
+					add(fields[0])
+				}
+			}
+		}
+	})
+	return links, nil
+}
+
+// documentBase returns the url relative references of the page resolve against, its <base href> if any.
+func documentBase(doc *goquery.Document, page *url.URL) *url.URL {
+	href, ok := doc.Find("base[href]").Attr("href")
+	if !ok || page == nil {
+		return page
+	}
+	if u, err := page.Parse(strings.TrimSpace(href)); err == nil {
+		return u
+	}
+	return page
+}
+
+// Canonicalize returns the canonical form of u, so urls of the same resource compare equal:
+// the scheme and host are lowercased, internationalized hosts are punycoded, the default port
+// is dropped, an empty path becomes /, the fragment and TrackingParams are removed and
+// the query is sorted by key. u is not modified.
+func Canonicalize(u *url.URL) *url.URL {
+	c := *u
+	if c.User != nil {
+		user := *c.User
+		c.User = &user
+	}
+	if c.Opaque != "" {
+		return &c
+	}
+	c.Scheme = strings.ToLower(c.Scheme)
+	c.Fragment = ""
+
+	host, port := c.Hostname(), c.Port()
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if ascii, err := idna.Lookup.ToASCII(host); err == nil {
+		host = ascii
+	}
+	if port == defaultPorts[c.Scheme] {
+		port = ""
+	}
+	switch {
+	case port != "":
+		c.Host = net.JoinHostPort(host, port)
+	case strings.Contains(host, ":"):
+		// an IPv6 address
+		c.Host = "[" + host + "]"
+	default:
+		c.Host = host
+	}
+
+	if c.Path == "" && c.Host != "" {
+		c.Path, c.RawPath = "/", ""
+	}
+	c.RawQuery = canonicalQuery(c.RawQuery)
+	c.ForceQuery = false
+	return &c
+}
+
+// CanonicalURL parses rawURL and returns its Canonicalize form.
+func CanonicalURL(rawURL string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	return Canonicalize(u).String(), nil
+}
+
+func canonicalQuery(rawQuery string) string {
+	if rawQuery == "" {
+		return ""
+	}
+	type pair struct{ key, raw string }
+	var pairs []pair
+	for _, part := range strings.Split(rawQuery, "&") {
+		if part == "" {
+			continue
+		}
+		key := part
+		if i := strings.IndexByte(part, '='); i >= 0 {
+			key = part[:i]
+		}
+		name, err := url.QueryUnescape(key)
+		if err != nil {
+			name = key
+		}
+		if isTrackingParam(name) {
+			continue
+		}
+		pairs = append(pairs, pair{key, part})
+	}
+	// values of the same key keep their order
+	sort.SliceStable(pairs, func(i, j int) bool { return pairs[i].key < pairs[j].key })
+	parts := make([]string, len(pairs))
+	for i, p := range pairs {
+		parts[i] = p.raw
+	}
+	return strings.Join(parts, "&")
+}
+
+func isTrackingParam(name string) bool {
+	name = strings.ToLower(name)
+	for _, param := range TrackingParams {
+		if strings.HasSuffix(param, "*") && strings.HasPrefix(name, param[:len(param)-1]) || name == param {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,258 @@
+package httpclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultRefreshBefore = 30 * time.Second
+
+// Token is an OAuth2 access token.
+type Token struct {
+	AccessToken  string
+	TokenType    string
+	RefreshToken string
+	Expiry       time.Time // zero if the token does not expire
+}
+
+func (t *Token) expiresWithin(d time.Duration) bool {
+	return !t.Expiry.IsZero() && time.Now().Add(d).After(t.Expiry)
+}
+
+func (t *Token) authorization() string {
+	tokenType := t.TokenType
+	if tokenType == "" || strings.EqualFold(tokenType, "bearer") {
+		tokenType = "Bearer"
+	}
+	return tokenType + " " + t.AccessToken
+}
+
+// TokenSource supplies the tokens sent in the Authorization header.
+type TokenSource interface {
+	Token(ctx context.Context) (*Token, error)
+}
+
+// TokenError is the error response of a token endpoint.
+type TokenError struct {
+	StatusCode  int
+	Code        string
+	Description string
+}
+
+func (e *TokenError) Error() string {
+	if e.Description != "" {
+		return fmt.Sprintf("httpclient: oauth2: %s: %s", e.Code, e.Description)
+	}
+	return fmt.Sprintf("httpclient: oauth2: token endpoint returned status %d %s", e.StatusCode, e.Code)
+}
+
+// OAuth2 authorizes the request with a token from ts. If the server answers 401 the request
+// is sent once more with a new token, a *CachedTokenSource drops the rejected one first.
+func (req *HttpRequest) OAuth2(ts TokenSource) *HttpRequest {
+	req.tokenSource = ts
+	return req
+}
+
+func (req *HttpRequest) authorizeToken(request *http.Request) error {
+	ctx := req.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	token, err := req.tokenSource.Token(ctx)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Authorization", token.authorization())
+	return nil
+}
+
+// invalidateToken drops the token a request was rejected with and reports whether a retry may succeed.
+func invalidateToken(ts TokenSource, request *http.Request) bool {
+	if cached, ok := ts.(*CachedTokenSource); ok {
+		cached.invalidate(request.Header.Get("Authorization"))
+	}
+	return true
+}
+
+// ClientCredentials fetches tokens with the client credentials grant.
+type ClientCredentials struct {
+	TokenURL       string
+	ClientID       string
+	ClientSecret   string
+	Scopes         []string
+	EndpointParams url.Values
+	AuthInBody     bool         // send the client credentials as form values instead of Basic auth
+	Client         *http.Client // http.DefaultClient if nil
+}
+
+func (c *ClientCredentials) Token(ctx context.Context) (*Token, error) {
+	params := url.Values{"grant_type": {"client_credentials"}}
+	if len(c.Scopes) > 0 {
+		params.Set("scope", strings.Join(c.Scopes, " "))
+	}
+	for k, vs := range c.EndpointParams {
+		params[k] = vs
+	}
+	return fetchToken(ctx, c.Client, c.TokenURL, c.ClientID, c.ClientSecret, c.AuthInBody, params)
+}
+
+// RefreshTokenSource fetches tokens with the refresh token grant,
+// a rotated refresh token returned by the server replaces RefreshToken.
+type RefreshTokenSource struct {
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	RefreshToken string
+	Scopes       []string
+	AuthInBody   bool
+	Client       *http.Client
+
+	mu sync.Mutex
+}
+
+func (s *RefreshTokenSource) Token(ctx context.Context) (*Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	params := url.Values{"grant_type": {"refresh_token"}, "refresh_token": {s.RefreshToken}}
+	if len(s.Scopes) > 0 {
+		params.Set("scope", strings.Join(s.Scopes, " "))
+	}
+	token, err := fetchToken(ctx, s.Client, s.TokenURL, s.ClientID, s.ClientSecret, s.AuthInBody, params)
+	if err != nil {
+		return nil, err
+	}
+	if token.RefreshToken == "" {
+		token.RefreshToken = s.RefreshToken
+	}
+	s.RefreshToken = token.RefreshToken
+	return token, nil
+}
+
+type tokenResponse struct {
+	AccessToken      string      `json:"access_token"`
+	TokenType        string      `json:"token_type"`
+	RefreshToken     string      `json:"refresh_token"`
+	ExpiresIn        json.Number `json:"expires_in"`
+	Error            string      `json:"error"`
+	ErrorDescription string      `json:"error_description"`
+}
+
+func fetchToken(ctx context.Context, cl *http.Client, tokenURL, clientID, clientSecret string, authInBody bool, params url.Values) (*Token, error) {
+	if cl == nil {
+		cl = http.DefaultClient
+	}
+	req := NewHttpRequest(cl).Post().Url(tokenURL).Context(ctx).Head("Accept", "application/json")
+	if authInBody {
+		params.Set("client_id", clientID)
+		if clientSecret != "" {
+			params.Set("client_secret", clientSecret)
+		}
+	} else {
+		req.BasicAuth(url.QueryEscape(clientID), url.QueryEscape(clientSecret))
+	}
+	for k, vs := range params {
+		req.ParamArray(k, vs)
+	}
+	resp := req.Send()
+	code, err := resp.Code()
+	if err != nil {
+		return nil, err
+	}
+	var tr tokenResponse
+	_ = resp.JSON(&tr)
+	if code < 200 || code > 299 || tr.AccessToken == "" {
+		return nil, &TokenError{StatusCode: code, Code: tr.Error, Description: tr.ErrorDescription}
+	}
+	token := &Token{AccessToken: tr.AccessToken, TokenType: tr.TokenType, RefreshToken: tr.RefreshToken}
+	if expiresIn, err := tr.ExpiresIn.Int64(); err == nil && expiresIn > 0 {
+		token.Expiry = time.Now().Add(time.Duration(expiresIn) * time.Second)
+	}
+	return token, nil
+}
+
+// CachedTokenSource reuses the token of Source until it expires.
+//
+// A token expiring within RefreshBefore is refreshed in the background while the
+// current one is still handed out, concurrent callers share a single refresh.
+type CachedTokenSource struct {
+	Source        TokenSource
+	RefreshBefore time.Duration // 30s if zero
+
+	mu      sync.Mutex
+	token   *Token
+	pending *tokenCall
+}
+
+type tokenCall struct {
+	done  chan struct{}
+	token *Token
+	err   error
+}
+
+// ReuseTokenSource wraps src in a CachedTokenSource.
+func ReuseTokenSource(src TokenSource) *CachedTokenSource {
+	return &CachedTokenSource{Source: src}
+}
+
+func (s *CachedTokenSource) refreshBefore() time.Duration {
+	if s.RefreshBefore > 0 {
+		return s.RefreshBefore
+	}
+	return defaultRefreshBefore
+}
+
+func (s *CachedTokenSource) Token(ctx context.Context) (*Token, error) {
+	s.mu.Lock()
+	token := s.token
+	if token != nil && !token.expiresWithin(0) {
+		if token.expiresWithin(s.refreshBefore()) {
+			s.refresh()
+		}
+		s.mu.Unlock()
+		return token, nil
+	}
+	call := s.refresh()
+	s.mu.Unlock()
+	select {
+	case <-call.done:
+		return call.token, call.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// refresh starts fetching a token unless a fetch is already running, s.mu must be held.
+// The fetch is shared, so it is not bound to the context of any caller.
+func (s *CachedTokenSource) refresh() *tokenCall {
+	if s.pending != nil {
+		return s.pending
+	}
+	call := &tokenCall{done: make(chan struct{})}
+	s.pending = call
+	go func() {
+		call.token, call.err = s.Source.Token(context.Background())
+		s.mu.Lock()
+		if call.err == nil {
+			s.token = call.token
+		}
+		s.pending = nil
+		s.mu.Unlock()
+		close(call.done)
+	}()
+	return call
+}
+
+// invalidate drops the cached token if it is the one sent as authorization.
+func (s *CachedTokenSource) invalidate(authorization string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.token != nil && s.token.authorization() == authorization {
+		s.token = nil
+	}
+}
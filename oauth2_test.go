@@ -0,0 +1,96 @@
+package httpclient
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// tokenServer issues the tokens t1, t2... with the client credentials grant, each after delay.
+func tokenServer(t *testing.T, delay time.Duration, issued *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, secret, _ := r.BasicAuth()
+		if r.Method != http.MethodPost || r.FormValue("grant_type") != "client_credentials" || id != "id" || secret != "secret" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":"invalid_request"}`))
+			return
+		}
+		time.Sleep(delay)
+		n := atomic.AddInt32(issued, 1)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token":"t%d","token_type":"bearer","expires_in":3600}`, n)
+	}))
+}
+
+func TestClientCredentials(t *testing.T) {
+	var issued int32
+	server := tokenServer(t, 0, &issued)
+	defer server.Close()
+
+	token, err := (&ClientCredentials{TokenURL: server.URL, ClientID: "id", ClientSecret: "secret"}).Token(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.AccessToken != "t1" || token.authorization() != "Bearer t1" || token.expiresWithin(time.Hour-time.Minute) {
+		t.Errorf("token %+v, want t1 expiring in an hour", token)
+	}
+	_, err = (&ClientCredentials{TokenURL: server.URL, ClientID: "id"}).Token(context.Background())
+	if e, ok := err.(*TokenError); !ok || e.StatusCode != http.StatusBadRequest || e.Code != "invalid_request" {
+		t.Errorf("err = %v, want the invalid_request TokenError", err)
+	}
+}
+
+func TestClientTokenSource(t *testing.T) {
+	var issued int32
+	tokens := tokenServer(t, 20*time.Millisecond, &issued)
+	defer tokens.Close()
+
+	var mu sync.Mutex
+	var authorizations []string
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		authorizations = append(authorizations, r.Header.Get("Authorization"))
+		mu.Unlock()
+		// t1 is revoked
+		if r.Header.Get("Authorization") == "Bearer t1" {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer api.Close()
+
+	cl := New(&http.Client{}).TokenSource(&ClientCredentials{TokenURL: tokens.URL, ClientID: "id", ClientSecret: "secret"})
+	if _, ok := cl.tokenSource.(*CachedTokenSource); !ok {
+		t.Fatalf("token source %T, want a *CachedTokenSource", cl.tokenSource)
+	}
+	// the first requests share a single fetch
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := cl.Get(api.URL).Send().CheckStatus(); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	// t1 was rejected, the retries share the fetch of t2 which is then cached
+	if err := cl.Get(api.URL).Send().CheckStatus(); err != nil {
+		t.Fatal(err)
+	}
+	if n := atomic.LoadInt32(&issued); n != 2 {
+		t.Errorf("%d tokens issued, want 2", n)
+	}
+	count := map[string]int{}
+	for _, authorization := range authorizations {
+		count[authorization]++
+	}
+	if count["Bearer t1"] != 5 || count["Bearer t2"] != 6 || len(count) != 2 {
+		t.Errorf("authorizations %v, want t1 5 times and t2 6 times", count)
+	}
+}
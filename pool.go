@@ -0,0 +1,251 @@
+package httpclient
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+
+	"golang.org/x/net/proxy"
+)
+
+const (
+	defaultMaxIdleConns        = 2000
+	defaultMaxIdleConnsPerHost = 100
+	defaultMaxConnsPerHost     = 2000
+	defaultIdleConnTimeout     = time.Minute
+)
+
+type PoolIsolation int
+
+const (
+	SharedPool PoolIsolation = iota // one pool for all sessions, one per proxy with SessionProxy
+	PerProxy                        // a pool per proxy, sessions behind the same proxy share it
+	PerSession                      // a pool per session, no connection is shared between sessions
+)
+
+// Pool configures the connection pools of a Builder. Zero values keep the defaults,
+// or the settings of Builder.Transport if it is set.
+type Pool struct {
+	MaxIdleConns        int           // 2000
+	MaxIdleConnsPerHost int           // 100
+	MaxConnsPerHost     int           // 2000
+	IdleConnTimeout     time.Duration // 1m
+	// Hosts overrides the limits per host, a host:port or a host for every port.
+	// Every host in it gets a transport of its own.
+	Hosts     map[string]HostPool
+	Isolation PoolIsolation
+	// SessionIdleTimeout closes the PerSession pools of the sessions without new requests for it,
+	// they are kept until CloseSession if zero.
+	SessionIdleTimeout time.Duration
+}
+
+// HostPool are the limits of the connections to one host.
+type HostPool struct {
+	MaxIdleConnsPerHost int
+	MaxConnsPerHost     int
+	IdleConnTimeout     time.Duration
+}
+
+func (p HostPool) apply(t *http.Transport) {
+	if p.MaxIdleConnsPerHost > 0 {
+		t.MaxIdleConnsPerHost = p.MaxIdleConnsPerHost
+	}
+	if p.MaxConnsPerHost > 0 {
+		t.MaxConnsPerHost = p.MaxConnsPerHost
+	}
+	if p.IdleConnTimeout > 0 {
+		t.IdleConnTimeout = p.IdleConnTimeout
+	}
+}
+
+func (p *Pool) apply(t *http.Transport) {
+	if p.MaxIdleConns > 0 {
+		t.MaxIdleConns = p.MaxIdleConns
+	}
+	HostPool{
+		MaxIdleConnsPerHost: p.MaxIdleConnsPerHost,
+		MaxConnsPerHost:     p.MaxConnsPerHost,
+		IdleConnTimeout:     p.IdleConnTimeout,
+	}.apply(t)
+}
+
+// transportSet are the round trippers of one pool, one for every host override and one for the rest.
+type transportSet struct {
+	shared   http.RoundTripper
+	hosts    map[string]http.RoundTripper
+	balanced *balancedTransport // over the set with the Balancer of the Builder
+	session  string             // of a PerSession pool
+	used     time.Time          // last request of a PerSession pool, zero for the others
+}
+
+func (s *transportSet) RoundTrip(request *http.Request) (*http.Response, error) {
+	if rt, ok := s.hosts[request.URL.Host]; ok {
+		return rt.RoundTrip(request)
+	}
+	if rt, ok := s.hosts[request.URL.Hostname()]; ok {
+		return rt.RoundTrip(request)
+	}
+	return s.shared.RoundTrip(request)
+}
+
+func (s *transportSet) CloseIdleConnections() {
+	closeIdle(s.shared)
+	for _, rt := range s.hosts {
+		closeIdle(rt)
+	}
+	if s.balanced != nil {
+		s.balanced.closeIdlePools()
+	}
+}
+
+func closeIdle(rt http.RoundTripper) {
+	if closer, ok := rt.(interface{ CloseIdleConnections() }); ok {
+		closer.CloseIdleConnections()
+	}
+}
+
+// transportFor returns the round tripper of the pool sessionID belongs to.
+func (builder *Builder) transportFor(sessionID string) http.RoundTripper {
+	proxyAddress := builder.Proxy
+	if builder.SessionProxy != nil {
+		proxyAddress = builder.SessionProxy(sessionID)
+	}
+	key := ""
+	if builder.SessionProxy != nil || builder.Pool.Isolation == PerProxy {
+		key = proxyAddress
+	}
+	if builder.Pool.Isolation == PerSession {
+		key = proxyAddress + "\x00" + sessionID
+	}
+
+	builder.poolMu.Lock()
+	defer builder.poolMu.Unlock()
+	now := time.Now()
+	builder.closeIdleSessions(now)
+	if set, ok := builder.transports[key]; ok {
+		if builder.Pool.Isolation == PerSession {
+			set.used = now
+		}
+		return set.roundTripper()
+	}
+	if builder.transports == nil {
+		builder.transports = make(map[string]*transportSet)
+	}
+	t := builder.Transport
+	if key != "" {
+		t = t.Clone()
+	}
+	t.DialContext = builder.dialer(proxyAddress)
+	base := t.Clone()
+	set := builder.newTransportSet(t)
+	if builder.Pool.Isolation == PerSession {
+		set.session, set.used = sessionID, now
+	}
+	if builder.Balancer != nil {
+		set.balanced = &balancedTransport{balancer: builder.Balancer, next: set,
+			newPool: func(address, endpoint string) http.RoundTripper {
+				return builder.newTransportSet(dialingEndpoint(base.Clone(), address, endpoint))
+			}}
+	}
+	builder.transports[key] = set
+	return set.roundTripper()
+}
+
+// newTransportSet returns the round trippers of a pool dialing with t.
+func (builder *Builder) newTransportSet(t *http.Transport) *transportSet {
+	set := &transportSet{hosts: make(map[string]http.RoundTripper, len(builder.Pool.Hosts))}
+	for host, hostPool := range builder.Pool.Hosts {
+		ht := t.Clone()
+		hostPool.apply(ht)
+		set.hosts[host] = configureProtocol(ht, builder.Protocol, builder.HTTP2)
+	}
+	set.shared = configureProtocol(t, builder.Protocol, builder.HTTP2)
+	return set
+}
+
+// roundTripper returns what the requests of the pool are sent with.
+func (s *transportSet) roundTripper() http.RoundTripper {
+	if s.balanced != nil {
+		return s.balanced
+	}
+	return s
+}
+
+// closeIdleSessions drops the PerSession pools idle for SessionIdleTimeout, builder.poolMu must be held.
+func (builder *Builder) closeIdleSessions(now time.Time) {
+	timeout := builder.Pool.SessionIdleTimeout
+	if timeout <= 0 || now.Sub(builder.swept) < timeout {
+		return
+	}
+	builder.swept = now
+	for key, set := range builder.transports {
+		if !set.used.IsZero() && now.Sub(set.used) >= timeout {
+			delete(builder.transports, key)
+			set.CloseIdleConnections()
+		}
+	}
+}
+
+// CloseSession closes the idle connections of the PerSession pool of sessionID and drops it,
+// the next request of the session gets a new pool.
+func (builder *Builder) CloseSession(sessionID string) {
+	builder.poolMu.Lock()
+	defer builder.poolMu.Unlock()
+	for key, set := range builder.transports {
+		if !set.used.IsZero() && set.session == sessionID {
+			delete(builder.transports, key)
+			set.CloseIdleConnections()
+		}
+	}
+}
+
+// dialer returns the dial function of connections through the SOCKS5 proxy at proxyAddress, direct if empty.
+func (builder *Builder) dialer(proxyAddress string) DialFunc {
+	dial := builder.DialContext
+	if dial == nil {
+		dial = (&net.Dialer{Timeout: builder.Timeout}).DialContext
+	}
+	switch {
+	case builder.UnixSocket != "":
+		dial = unixSocketDial(builder.UnixSocket, dial)
+	case builder.Resolver != nil:
+		dial = builder.Resolver.DialContext(dial)
+	}
+	if proxyAddress != "" {
+		proxyDialer, _ := proxy.SOCKS5("tcp", proxyAddress, builder.Auth, dial)
+		dial = proxyDialer.(proxy.ContextDialer).DialContext
+	}
+	if builder.Balancer != nil {
+		dial = builder.Balancer.DialContext(dial)
+	}
+	return dial
+}
+
+// CloseIdle closes the idle connections of all pools.
+func (builder *Builder) CloseIdle() {
+	builder.poolMu.Lock()
+	defer builder.poolMu.Unlock()
+	for _, set := range builder.transports {
+		set.CloseIdleConnections()
+	}
+}
+
+// Shutdown stops the Builder: new requests fail with ErrShutdown, requests in flight are
+// waited for and canceled once ctx is done. Then a Cache implementing CacheFlusher is flushed
+// and the idle connections are closed. It returns the error of ctx if requests had to be canceled.
+func (builder *Builder) Shutdown(ctx context.Context) error {
+	err := builder.lifecycle.shutdown(ctx)
+	if flusher, ok := builder.Cache.(CacheFlusher); ok {
+		flushCtx := ctx
+		if err != nil {
+			// the deadline has passed, the flush still deserves its chance
+			flushCtx = context.Background()
+		}
+		if flushErr := flusher.Flush(flushCtx); err == nil {
+			err = flushErr
+		}
+	}
+	builder.CloseIdle()
+	return err
+}
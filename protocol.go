@@ -0,0 +1,105 @@
+package httpclient
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"time"
+
+	"golang.org/x/net/http2"
+)
+
+type Protocol int
+
+const (
+	ProtocolAuto Protocol = iota // as net/http decides, HTTP/2 is off with a custom dialer or TLS config
+	HTTP1                        // HTTP/1.1 only
+	HTTP2                        // HTTP/2 over TLS negotiated with ALPN, HTTP/1.1 if the server does not offer it
+	H2C                          // HTTP/2 with prior knowledge over cleartext for http urls, as HTTP2 for https
+)
+
+// HTTP2Options configures the health checks of HTTP/2 connections.
+type HTTP2Options struct {
+	// ReadIdleTimeout is how long a connection may go without receiving a frame
+	// before it is pinged, health checks are off if zero.
+	ReadIdleTimeout time.Duration
+	// PingTimeout is how long a ping may go unanswered before the connection is closed, 15s if zero.
+	PingTimeout time.Duration
+}
+
+// configureProtocol sets up t for protocol and returns the RoundTripper sending the requests.
+func configureProtocol(t *http.Transport, protocol Protocol, options HTTP2Options) http.RoundTripper {
+	switch protocol {
+	case HTTP1:
+		t.ForceAttemptHTTP2 = false
+		t.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+		// a transport configured for HTTP/2 before offers h2 in ALPN
+		if t.TLSClientConfig != nil {
+			t.TLSClientConfig = t.TLSClientConfig.Clone()
+			t.TLSClientConfig.NextProtos = withoutProto(t.TLSClientConfig.NextProtos, "h2")
+		}
+	case HTTP2, H2C:
+		// a cloned transport may carry the h2 registration of its original
+		t.TLSNextProto = nil
+		h2, err := http2.ConfigureTransports(t)
+		if err != nil {
+			return t
+		}
+		h2.ReadIdleTimeout = options.ReadIdleTimeout
+		h2.PingTimeout = options.PingTimeout
+		if protocol == H2C {
+			return &h2cTransport{h2c: newH2CTransport(t, options), next: t}
+		}
+	}
+	return t
+}
+
+// withoutProto returns protos without proto.
+func withoutProto(protos []string, proto string) []string {
+	var kept []string
+	for _, p := range protos {
+		if p != proto {
+			kept = append(kept, p)
+		}
+	}
+	return kept
+}
+
+// h2cTransport sends http requests as HTTP/2 cleartext and the others with next.
+type h2cTransport struct {
+	h2c  *http2.Transport
+	next http.RoundTripper
+}
+
+func (t *h2cTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	if request.URL.Scheme == "http" {
+		return t.h2c.RoundTrip(request)
+	}
+	return t.next.RoundTrip(request)
+}
+
+func (t *h2cTransport) CloseIdleConnections() {
+	t.h2c.CloseIdleConnections()
+	if closer, ok := t.next.(interface{ CloseIdleConnections() }); ok {
+		closer.CloseIdleConnections()
+	}
+}
+
+func newH2CTransport(t *http.Transport, options HTTP2Options) *http2.Transport {
+	dial := transportDial(t)
+	return &http2.Transport{
+		AllowHTTP: true,
+		// the connection is plain TCP despite the name
+		DialTLS: func(network, address string, _ *tls.Config) (net.Conn, error) {
+			return dial(context.Background(), network, address)
+		},
+		ReadIdleTimeout: options.ReadIdleTimeout,
+		PingTimeout:     options.PingTimeout,
+	}
+}
+
+// Proto returns the protocol of the response, like HTTP/1.1 or HTTP/2.0.
+func (resp *HttpResponse) Proto() string {
+	return resp.proto
+}
@@ -0,0 +1,234 @@
+package httpclient
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const defaultMaxRedirects = 10
+
+type RedirectKind int
+
+const (
+	HTTPRedirect       RedirectKind = iota // a 3xx response
+	MetaRefresh                            // <meta http-equiv="refresh">
+	JavaScriptRedirect                     // window.location = "..." in a script
+)
+
+// Redirect is a hop answered with a redirect on the way to the final response.
+type Redirect struct {
+	Kind       RedirectKind
+	StatusCode int
+	URL        *url.URL // url of the request that was redirected
+	Location   *url.URL // resolved target of the redirect
+	Header     http.Header
+	Cookies    []*http.Cookie // set by the redirect response
+}
+
+func newRedirect(response *http.Response, location *url.URL) Redirect {
+	return Redirect{
+		StatusCode: response.StatusCode,
+		URL:        response.Request.URL,
+		Location:   location,
+		Header:     response.Header,
+		Cookies:    response.Cookies(),
+	}
+}
+
+// History returns the redirects followed to get the response, the first one first.
+func (resp *HttpResponse) History() []Redirect {
+	return resp.history
+}
+
+// RedirectPolicy decides which redirects are followed and how.
+type RedirectPolicy struct {
+	MaxHops int // 10 if zero, if negative redirect responses are returned as they are
+	// SameHost only follows redirects to the host of the original request or to AllowedHosts.
+	SameHost bool
+	// AllowedHosts restricts redirects to the original host and these hosts, *.example.com matches subdomains.
+	AllowedHosts []string
+	// AllowDowngrade follows redirects from https to http.
+	AllowDowngrade bool
+	// KeepCredentials keeps the Authorization and Cookie headers, the token source and the signer
+	// on redirects to another origin.
+	KeepCredentials bool
+	// PreserveMethod keeps the method and body on 301 and 302 as on 307 and 308.
+	// Otherwise they turn into GET for all methods but GET and HEAD, a 303 always does.
+	PreserveMethod bool
+}
+
+// RedirectError is returned when the RedirectPolicy refuses to follow a redirect.
+type RedirectError struct {
+	URL      *url.URL
+	Location *url.URL
+	Reason   string
+}
+
+func (e *RedirectError) Error() string {
+	return fmt.Sprintf("httpclient: redirect from %s to %s refused: %s", e.URL, e.Location, e.Reason)
+}
+
+// Redirects follows redirects by policy instead of the CheckRedirect of the http.Client.
+func (req *HttpRequest) Redirects(policy *RedirectPolicy) *HttpRequest {
+	req.redirectPolicy = policy
+	return req
+}
+
+func (p *RedirectPolicy) check(origin, from, to *url.URL, hops int) error {
+	maxHops := p.MaxHops
+	if maxHops == 0 {
+		maxHops = defaultMaxRedirects
+	}
+	if hops > maxHops {
+		return &RedirectError{URL: from, Location: to, Reason: fmt.Sprintf("stopped after %d redirects", maxHops)}
+	}
+	if from.Scheme == "https" && to.Scheme == "http" && !p.AllowDowngrade {
+		return &RedirectError{URL: from, Location: to, Reason: "https downgraded to http"}
+	}
+	if (p.SameHost || len(p.AllowedHosts) > 0) && !p.hostAllowed(origin, to) {
+		return &RedirectError{URL: from, Location: to, Reason: "host not allowed"}
+	}
+	return nil
+}
+
+func (p *RedirectPolicy) hostAllowed(origin, to *url.URL) bool {
+	host := strings.ToLower(to.Hostname())
+	if host == strings.ToLower(origin.Hostname()) {
+		return true
+	}
+	for _, allowed := range p.AllowedHosts {
+		allowed = strings.ToLower(allowed)
+		if host == allowed || strings.HasPrefix(allowed, "*.") && strings.HasSuffix(host, allowed[1:]) {
+			return true
+		}
+	}
+	return false
+}
+
+func isRedirect(code int) bool {
+	switch code {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther,
+		http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return true
+	}
+	return false
+}
+
+func sameOrigin(a, b *url.URL) bool {
+	return a.Scheme == b.Scheme && strings.EqualFold(a.Host, b.Host)
+}
+
+// follow sends the request and follows its redirects, by the RedirectPolicy if there is one.
+func (req *HttpRequest) follow(target string, body []byte) (*http.Response, []Redirect, error) {
+	if req.redirectPolicy == nil {
+		response, err := req.roundTrip(target, body)
+		if err != nil {
+			return response, nil, err
+		}
+		return response, redirectHistory(response), nil
+	}
+
+	origin, hops := req.redirectOrigin, req.redirectHops
+	if origin == nil {
+		var err error
+		if origin, err = url.Parse(target); err != nil {
+			return nil, nil, err
+		}
+	}
+	client := *req.client
+	client.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	hop := req.Clone()
+	hop.client = &client
+	var history []Redirect
+	for {
+		response, err := hop.roundTrip(target, body)
+		if err != nil || !isRedirect(response.StatusCode) || response.Header.Get("Location") == "" ||
+			req.redirectPolicy.MaxHops < 0 {
+			return response, history, err
+		}
+		from := response.Request.URL
+		to, err := from.Parse(response.Header.Get("Location"))
+		_, _ = io.Copy(ioutil.Discard, response.Body)
+		_ = response.Body.Close()
+		if err != nil {
+			return nil, history, err
+		}
+		history = append(history, newRedirect(response, to))
+
+		next := hop.Clone()
+		switch response.StatusCode {
+		case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther:
+			if hop.method != http.MethodGet && hop.method != http.MethodHead &&
+				(response.StatusCode == http.StatusSeeOther || !req.redirectPolicy.PreserveMethod) {
+				next.method = http.MethodGet
+				next.header.Del("Content-Type")
+				body = nil
+			}
+		}
+		next.prepareHop(from, to, req.redirectPolicy.KeepCredentials)
+		if err := next.checkHop(origin, from, to, hops+len(history)); err != nil {
+			return nil, history, err
+		}
+		hop, target = next, to.String()
+	}
+}
+
+// checkHop checks a redirect by the RedirectPolicy and the robots.txt of req,
+// hops counts the redirects followed since origin, HTML redirects included.
+func (req *HttpRequest) checkHop(origin, from, to *url.URL, hops int) error {
+	if req.redirectPolicy != nil {
+		if err := req.redirectPolicy.check(origin, from, to, hops); err != nil {
+			return err
+		}
+	}
+	if req.robots != nil {
+		return req.robots.check(req, to.String())
+	}
+	return nil
+}
+
+// prepareHop sets up a request following a redirect from one url to another.
+func (req *HttpRequest) prepareHop(from, to *url.URL, keepCredentials bool) {
+	// a Host override is meant for the origin it was set for
+	if !sameOrigin(from, to) {
+		req.host = ""
+	}
+	if !sameOrigin(from, to) && !keepCredentials {
+		req.dropCredentials()
+	}
+	if !(from.Scheme == "https" && to.Scheme == "http") {
+		req.header.Set("Referer", from.String())
+	}
+}
+
+// dropCredentials removes what authenticates req, before it is sent to another origin.
+func (req *HttpRequest) dropCredentials() {
+	req.header.Del("Authorization")
+	req.header.Del("Cookie")
+	for _, name := range req.apiKeys {
+		req.header.Del(name)
+	}
+	req.cookies = nil
+	req.tokenSource = nil
+	req.digest = nil
+	req.signer = nil
+}
+
+// redirectHistory collects the redirects the http.Client followed to get response.
+func redirectHistory(response *http.Response) []Redirect {
+	var history []Redirect
+	for r := response.Request.Response; r != nil && r.Request != nil; r = r.Request.Response {
+		location, err := r.Request.URL.Parse(r.Header.Get("Location"))
+		if err != nil {
+			location = nil
+		}
+		history = append([]Redirect{newRedirect(r, location)}, history...)
+	}
+	return history
+}
@@ -9,7 +9,10 @@ import (
 	"io/ioutil"
 	"net/http"
 	"net/http/httputil"
+	"net/url"
 	"os"
+	"sort"
+	"time"
 
 	"github.com/cocotyty/cookiejar"
 	"golang.org/x/net/publicsuffix"
@@ -24,7 +27,10 @@ type HttpRequest struct {
 	ctx            context.Context
 	err            error
 	method         string
+	baseURL        string
 	url            string
+	path           string
+	pathParams     map[string]string
 	host           string
 	encoding       encoding.Encoding
 	header         http.Header
@@ -39,6 +45,22 @@ type HttpRequest struct {
 	UserAgentsPool []string
 	dumpRequest    io.WriteCloser
 	dumpResponse   io.WriteCloser
+	digest         *Digest
+	apiKeys        []string // headers set by APIKey
+	tokenSource    TokenSource
+	signer         Signer
+	breaker        *CircuitBreaker
+	hedgeDelay     time.Duration
+	hedgeExtra     int
+	hedgeAny       bool
+	hedgeStats     *HedgeStats
+	tracker        *tracker
+	redirectPolicy *RedirectPolicy
+	htmlRedirects  int
+	robots         *Robots
+	// the url sent first and the redirects followed to get to this request, when it is a hop
+	redirectOrigin *url.URL
+	redirectHops   int
 }
 
 func NewHttpRequest(client *http.Client) *HttpRequest {
@@ -113,8 +135,9 @@ func (req *HttpRequest) AddCookie(ck *http.Cookie) *HttpRequest {
 	return req
 }
 
-func (req *HttpRequest) Context(ctx context.Context){
+func (req *HttpRequest) Context(ctx context.Context) *HttpRequest {
 	req.ctx = ctx
+	return req
 }
 
 func (req *HttpRequest) Url(url string) *HttpRequest {
@@ -122,6 +145,28 @@ func (req *HttpRequest) Url(url string) *HttpRequest {
 	return req
 }
 
+// BaseURL sets the prefix that relative urls and paths are joined to.
+func (req *HttpRequest) BaseURL(base string) *HttpRequest {
+	req.baseURL = base
+	return req
+}
+
+// Path appends a path template such as "/users/{id}/repos" to the request url.
+// Placeholders are filled from PathParam.
+func (req *HttpRequest) Path(path string) *HttpRequest {
+	req.path = path
+	return req
+}
+
+// PathParam sets the value of a path placeholder, the value is path escaped.
+func (req *HttpRequest) PathParam(k, v string) *HttpRequest {
+	if req.pathParams == nil {
+		req.pathParams = make(map[string]string)
+	}
+	req.pathParams[k] = v
+	return req
+}
+
 func (req *HttpRequest) Query(k, v string) *HttpRequest {
 	if req.querys == nil {
 		req.querys = [][]string{}
@@ -139,6 +184,20 @@ func (req *HttpRequest) QueryArray(k string, vs []string) *HttpRequest {
 	}
 	return req
 }
+
+// QueryValues appends all values, keys are added in sorted order.
+func (req *HttpRequest) QueryValues(values url.Values) *HttpRequest {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		req.QueryArray(k, values[k])
+	}
+	return req
+}
+
 func (req *HttpRequest) Param(k string, v string) *HttpRequest {
 	if req.params == nil {
 		req.params = make(map[string][]string)
@@ -180,6 +239,11 @@ func (req *HttpRequest) UserAgentInHeader(userAgent string) *HttpRequest {
 	return req.Head("User-Agent", userAgent)
 }
 
+// UserAgent returns the User-Agent header of the request.
+func (req *HttpRequest) UserAgent() string {
+	return req.header.Get("User-Agent")
+}
+
 func (req *HttpRequest) AutoSelectUserAgent() *HttpRequest {
 	if req.UserAgentsPool == nil || len(req.UserAgentsPool) == 0 {
 		return req.UserAgentInHeader(defaultUA)
@@ -225,6 +289,7 @@ func (d *dumpPrinter) Close() error {
 		os.Stderr.Write(line)
 		os.Stderr.Write([]byte{'\n'})
 	}
+	d.Buffer.Reset()
 	return nil
 }
 
@@ -238,69 +303,167 @@ func (req *HttpRequest) Send() (resp *HttpResponse) {
 	if req.err != nil {
 		return &HttpResponse{err: req.err}
 	}
+	if req.tracker != nil {
+		if err := req.tracker.begin(req); err != nil {
+			return &HttpResponse{err: err}
+		}
+		defer req.tracker.end(req)
+	}
+	target, err := req.buildURL()
+	if err != nil {
+		return &HttpResponse{err: err}
+	}
+	if req.robots != nil {
+		if err := req.robots.check(req, target); err != nil {
+			return &HttpResponse{err: err}
+		}
+	}
+	body, err := req.encodeBody()
+	if err != nil {
+		return &HttpResponse{err: err}
+	}
+	if req.hedgeExtra > 0 && (req.hedgeAny || safeMethod(req.method)) {
+		resp = req.hedge(target, body)
+	} else {
+		resp = req.exchange(target, body)
+	}
+	if req.htmlRedirects > 0 {
+		resp = req.followHTMLRedirects(resp)
+	}
+	resp.request = req
+	return resp
+}
+
+// exchange sends the request and reads the whole response.
+func (req *HttpRequest) exchange(target string, body []byte) (resp *HttpResponse) {
 	resp = &HttpResponse{}
-	var err error
-	if req.querys != nil {
-		req.url = req.url + "?" + string(encodeQuery(req.querys, req.encoding))
+	if req.storeCookie != nil {
+		// cookies set by redirects are kept even if the request fails later
+		defer req.storeCookie(req.sessionID, req.client.Jar)
+	}
+	response, history, err := req.follow(target, body)
+	if response != nil && response.Body != nil {
+		defer response.Body.Close()
+	}
+	resp.history = history
+	if err != nil {
+		resp.err = err
+		return
 	}
+	data, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		resp.err = err
+		return
+	}
+	return &HttpResponse{code: response.StatusCode, body: data, header: response.Header, url: response.Request.URL,
+		proto: response.Proto, history: history}
+}
+
+func (req *HttpRequest) encodeBody() (body []byte, err error) {
+	body = req.body
 	if req.params != nil {
-		req.body = encodeForm(req.params, req.encoding)
+		body = encodeForm(req.params, req.encoding)
 	}
 	if req.jsonData != nil {
 		req.header.Set("Content-Type", "application/json")
-		req.body, err = json.Marshal(req.jsonData)
-		if err != nil {
-			resp.err = err
-			return
-		}
+		body, err = json.Marshal(req.jsonData)
 	}
-	request, err := http.NewRequest(req.method, req.url, bytes.NewReader(req.body))
+	return
+}
+
+// newHTTPRequest builds the request sent to target, the buffered body allows it to be built again for a retry.
+func (req *HttpRequest) newHTTPRequest(target string, body []byte) (*http.Request, error) {
+	request, err := http.NewRequest(req.method, target, bytes.NewReader(body))
 	if err != nil {
-		resp.err = err
-		return
+		return nil, err
 	}
-	if req.ctx != nil{
+	if req.ctx != nil {
 		request = request.WithContext(req.ctx)
 	}
+	if req.sessionID != "" {
+		request = request.WithContext(context.WithValue(request.Context(), sessionIDKey{}, req.sessionID))
+	}
 	if req.host != "" {
 		request.Host = req.host
 	}
 	if req.cookies != nil {
-		if req.client.Jar == nil {
-			req.client.Jar, _ = cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
+		req.cookieJar().SetCookies(request.URL, req.cookies)
+	}
+	request.Header = req.header.Clone()
+	if req.tokenSource != nil {
+		if err := req.authorizeToken(request); err != nil {
+			return nil, err
+		}
+	}
+	if req.digest != nil {
+		req.digest.authorize(request, body)
+	}
+	if req.signer != nil {
+		if err := req.signer.Sign(request, body); err != nil {
+			return nil, err
 		}
-		req.client.Jar.SetCookies(request.URL, req.cookies)
 	}
-	request.Header = req.header
+	return request, nil
+}
+
+// cookieJar returns the cookie jar of the client, created if it has none.
+func (req *HttpRequest) cookieJar() http.CookieJar {
+	if req.client.Jar == nil {
+		req.client.Jar, _ = cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
+	}
+	return req.client.Jar
+}
+
+func (req *HttpRequest) roundTrip(target string, body []byte) (*http.Response, error) {
+	request, err := req.newHTTPRequest(target, body)
+	if err != nil {
+		return nil, err
+	}
+	response, err := req.do(request)
+	if err != nil || response.StatusCode != http.StatusUnauthorized {
+		return response, err
+	}
+	switch {
+	case req.digest != nil && req.digest.handleChallenge(request, response):
+	case req.tokenSource != nil && invalidateToken(req.tokenSource, request):
+	default:
+		return response, nil
+	}
+	_, _ = io.Copy(ioutil.Discard, response.Body)
+	_ = response.Body.Close()
+	if request, err = req.newHTTPRequest(target, body); err != nil {
+		return nil, err
+	}
+	return req.do(request)
+}
 
+func (req *HttpRequest) do(request *http.Request) (*http.Response, error) {
 	if req.dumpRequest != nil {
 		data, _ := httputil.DumpRequest(request, true)
 		_, _ = req.dumpRequest.Write(data)
 		_ = req.dumpRequest.Close()
 	}
 
-	response, err := req.client.Do(request)
+	response, err := req.send(request)
 
 	if req.dumpResponse != nil && response != nil {
 		data, _ := httputil.DumpResponse(response, true)
 		_, _ = req.dumpResponse.Write(data)
 		_ = req.dumpResponse.Close()
 	}
+	return response, err
+}
 
-	if response != nil && response.Body != nil {
-		defer response.Body.Close()
+func (req *HttpRequest) send(request *http.Request) (*http.Response, error) {
+	if req.breaker == nil {
+		return req.client.Do(request)
 	}
+	key := req.breaker.key(request)
+	generation, err := req.breaker.allow(key)
 	if err != nil {
-		resp.err = err
-		return
-	}
-	data, err := ioutil.ReadAll(response.Body)
-	if err != nil {
-		resp.err = err
-		return
+		return nil, err
 	}
-	if req.storeCookie != nil {
-		req.storeCookie(req.sessionID, req.client.Jar)
-	}
-	return &HttpResponse{code: response.StatusCode, body: data, header: response.Header, url: response.Request.URL}
+	response, err := req.client.Do(request)
+	req.breaker.done(key, generation, response, err)
+	return response, err
 }
@@ -0,0 +1,232 @@
+package httpclient
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultDNSTTL         = time.Minute
+	defaultNegativeTTL    = 5 * time.Second
+	defaultFallbackDelay  = 300 * time.Millisecond
+	defaultDNSDialTimeout = 5 * time.Second
+)
+
+// DialFunc dials connections like net.Dialer.DialContext.
+type DialFunc func(ctx context.Context, network, address string) (net.Conn, error)
+
+func (f DialFunc) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	return f(ctx, network, address)
+}
+
+func (f DialFunc) Dial(network, address string) (net.Conn, error) {
+	return f(context.Background(), network, address)
+}
+
+// Resolver resolves the hosts dialed by the client, like curl --resolve plus a DNS cache.
+//
+// Hosts maps a host to the IPs it resolves to without asking DNS. Other hosts are looked up
+// with the DNS server at Server, or the system resolver if empty, and cached for TTL.
+// Hosts that do not exist are cached for NegativeTTL. Connections race IPv6 and IPv4
+// addresses as in Happy Eyeballs, the other family is tried after FallbackDelay.
+type Resolver struct {
+	Hosts         map[string][]string
+	Server        string        // host:port of the DNS server
+	TTL           time.Duration // 1m if zero
+	NegativeTTL   time.Duration // 5s if zero
+	FallbackDelay time.Duration // 300ms if zero
+
+	once     sync.Once
+	resolver *net.Resolver
+	mu       sync.Mutex
+	cache    map[string]*dnsEntry
+}
+
+type dnsEntry struct {
+	ips     []net.IP
+	err     error
+	expires time.Time
+}
+
+func (r *Resolver) init() {
+	r.resolver = net.DefaultResolver
+	if r.Server != "" {
+		r.resolver = &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+				d := net.Dialer{Timeout: defaultDNSDialTimeout}
+				return d.DialContext(ctx, network, r.Server)
+			},
+		}
+	}
+	r.cache = make(map[string]*dnsEntry)
+}
+
+// LookupIP returns the IPs of host.
+func (r *Resolver) LookupIP(ctx context.Context, host string) ([]net.IP, error) {
+	r.once.Do(r.init)
+	if ip := net.ParseIP(host); ip != nil {
+		return []net.IP{ip}, nil
+	}
+	host = strings.ToLower(host)
+	if overrides, ok := r.Hosts[host]; ok {
+		ips := make([]net.IP, 0, len(overrides))
+		for _, s := range overrides {
+			if ip := net.ParseIP(s); ip != nil {
+				ips = append(ips, ip)
+			}
+		}
+		return ips, nil
+	}
+
+	r.mu.Lock()
+	entry, ok := r.cache[host]
+	r.mu.Unlock()
+	if ok && time.Now().Before(entry.expires) {
+		return entry.ips, entry.err
+	}
+
+	addrs, err := r.resolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		if dnsErr, ok := err.(*net.DNSError); ok && dnsErr.IsNotFound {
+			r.store(host, &dnsEntry{err: err, expires: time.Now().Add(orDefaultDuration(r.NegativeTTL, defaultNegativeTTL))})
+		}
+		return nil, err
+	}
+	ips := make([]net.IP, len(addrs))
+	for i, addr := range addrs {
+		ips[i] = addr.IP
+	}
+	r.store(host, &dnsEntry{ips: ips, expires: time.Now().Add(orDefaultDuration(r.TTL, defaultDNSTTL))})
+	return ips, nil
+}
+
+func (r *Resolver) store(host string, entry *dnsEntry) {
+	r.mu.Lock()
+	r.cache[host] = entry
+	r.mu.Unlock()
+}
+
+// DialContext returns a DialFunc that resolves the host with r and dials the IPs with dial.
+func (r *Resolver) DialContext(dial DialFunc) DialFunc {
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		switch network {
+		case "tcp", "tcp4", "tcp6", "udp", "udp4", "udp6":
+		default:
+			return dial(ctx, network, address)
+		}
+		host, port, err := net.SplitHostPort(address)
+		if err != nil {
+			return nil, err
+		}
+		ips, err := r.LookupIP(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		primary, fallback := splitFamilies(network, ips)
+		if len(primary) == 0 {
+			return nil, &net.DNSError{Err: "no suitable address found", Name: host}
+		}
+		if len(fallback) == 0 {
+			return dialSerial(ctx, dial, network, primary, port)
+		}
+		return r.dialParallel(ctx, dial, network, primary, fallback, port)
+	}
+}
+
+// splitFamilies splits ips into those of the family of the first one and the others.
+func splitFamilies(network string, ips []net.IP) (primary, fallback []net.IP) {
+	for _, ip := range ips {
+		v4 := ip.To4() != nil
+		if strings.HasSuffix(network, "4") && !v4 || strings.HasSuffix(network, "6") && v4 {
+			continue
+		}
+		if len(primary) == 0 || (primary[0].To4() != nil) == v4 {
+			primary = append(primary, ip)
+		} else {
+			fallback = append(fallback, ip)
+		}
+	}
+	return
+}
+
+func dialSerial(ctx context.Context, dial DialFunc, network string, ips []net.IP, port string) (net.Conn, error) {
+	var err error
+	for _, ip := range ips {
+		var conn net.Conn
+		if conn, err = dial(ctx, network, net.JoinHostPort(ip.String(), port)); err == nil {
+			return conn, nil
+		}
+		if ctx.Err() != nil {
+			return nil, err
+		}
+	}
+	return nil, err
+}
+
+type dialResult struct {
+	conn net.Conn
+	err  error
+}
+
+// dialParallel dials primary and starts fallback once FallbackDelay has passed or primary failed.
+func (r *Resolver) dialParallel(ctx context.Context, dial DialFunc, network string, primary, fallback []net.IP, port string) (net.Conn, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	results := make(chan dialResult, 2)
+	start := func(ips []net.IP) {
+		go func() {
+			conn, err := dialSerial(ctx, dial, network, ips, port)
+			results <- dialResult{conn: conn, err: err}
+		}()
+	}
+	start(primary)
+	timer := time.NewTimer(orDefaultDuration(r.FallbackDelay, defaultFallbackDelay))
+	defer timer.Stop()
+	pending, fallbackStarted := 1, false
+	var firstErr error
+	for {
+		select {
+		case <-timer.C:
+			if !fallbackStarted {
+				start(fallback)
+				fallbackStarted = true
+				pending++
+			}
+		case result := <-results:
+			pending--
+			if result.err == nil {
+				if pending > 0 {
+					// the losing dial is canceled, close it if it connected anyway
+					go func() {
+						if lost := <-results; lost.conn != nil {
+							lost.conn.Close()
+						}
+					}()
+				}
+				return result.conn, nil
+			}
+			if firstErr == nil {
+				firstErr = result.err
+			}
+			if !fallbackStarted {
+				start(fallback)
+				fallbackStarted = true
+				pending++
+			}
+			if pending == 0 {
+				return nil, firstErr
+			}
+		}
+	}
+}
+
+func orDefaultDuration(d, defaultValue time.Duration) time.Duration {
+	if d > 0 {
+		return d
+	}
+	return defaultValue
+}
@@ -2,8 +2,10 @@ package httpclient
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/url"
+	"sync"
 
 	"golang.org/x/text/encoding"
 	"golang.org/x/text/encoding/htmlindex"
@@ -16,6 +18,14 @@ type HttpResponse struct {
 	body     []byte
 	url      *url.URL
 	encoding encoding.Encoding
+	proto    string
+	history  []Redirect
+	// charset and confidence are set by AutoDecode
+	charset    string
+	confidence float64
+	detection  sync.Once
+	detected   encoding.Encoding // by bodyEncoding, without AutoDecode
+	request    *HttpRequest      // the request that got the response
 }
 
 func (resp *HttpResponse) Code() (int, error) {
@@ -25,6 +35,33 @@ func (resp *HttpResponse) Code() (int, error) {
 	return resp.code, nil
 }
 
+// StatusError describes a response whose status code is 400 or above.
+type StatusError struct {
+	Code   int
+	Header http.Header
+	Body   []byte
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("httpclient: unexpected status %d %s", e.Code, http.StatusText(e.Code))
+}
+
+// JSON decodes the error body into v.
+func (e *StatusError) JSON(v interface{}) error {
+	return json.Unmarshal(e.Body, v)
+}
+
+// CheckStatus returns the request error, or a *StatusError if the status code is 400 or above.
+func (resp *HttpResponse) CheckStatus() error {
+	if resp.err != nil {
+		return resp.err
+	}
+	if resp.code >= http.StatusBadRequest {
+		return &StatusError{Code: resp.code, Header: resp.header, Body: resp.body}
+	}
+	return nil
+}
+
 func (resp *HttpResponse) Body() ([]byte, error) {
 	if resp.err != nil {
 		return nil, resp.err
@@ -44,21 +81,22 @@ func (resp *HttpResponse) String() (string, error) {
 	if resp.err != nil {
 		return "", resp.err
 	}
-	if resp.encoding == nil {
-		return string(resp.body), nil
-	}
-	data, err := resp.encoding.NewDecoder().Bytes(resp.body)
+	data, err := resp.decodedBody()
 	if err != nil {
 		return "", err
 	}
-	return string(data), err
+	return string(data), nil
 }
 
 func (resp *HttpResponse) JSON(data interface{}) error {
 	if resp.err != nil {
 		return resp.err
 	}
-	return json.Unmarshal(resp.body, data)
+	body, err := resp.decode(resp.jsonEncoding())
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(body, data)
 }
 
 // http://www.w3.org/TR/encoding
@@ -0,0 +1,417 @@
+package httpclient
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+const (
+	defaultRobotsPrefix   = "robots/"
+	defaultRobotsTTL      = 24 * time.Hour
+	defaultRobotsErrorTTL = time.Minute
+	// the User-Agent net/http sends when the request has none
+	defaultGoUserAgent = "Go-http-client/1.1"
+)
+
+// robots.txt of an origin that failed to serve it, nothing may be fetched
+const disallowAllRobots = "User-agent: *\nDisallow: /\n"
+
+// RobotsTxt is a parsed robots.txt file.
+type RobotsTxt struct {
+	groups   []robotsGroup
+	sitemaps []string
+}
+
+type robotsGroup struct {
+	agents     []string // lowercased product tokens
+	rules      []robotsRule
+	crawlDelay time.Duration
+}
+
+type robotsRule struct {
+	allow   bool
+	path    string
+	pattern *regexp.Regexp // set if path has wildcards
+}
+
+func (rule robotsRule) match(path string) bool {
+	if rule.pattern != nil {
+		return rule.pattern.MatchString(path)
+	}
+	return strings.HasPrefix(path, rule.path)
+}
+
+// ParseRobotsTxt parses a robots.txt file, the lines it does not understand are ignored.
+func ParseRobotsTxt(data []byte) *RobotsTxt {
+	robots := &RobotsTxt{}
+	current := -1
+	// consecutive User-agent lines open a single group
+	inAgents := false
+	for _, line := range strings.Split(string(data), "\n") {
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		i := strings.IndexByte(line, ':')
+		if i < 0 {
+			continue
+		}
+		key, value := strings.ToLower(strings.TrimSpace(line[:i])), strings.TrimSpace(line[i+1:])
+		switch key {
+		case "user-agent":
+			if !inAgents {
+				robots.groups = append(robots.groups, robotsGroup{})
+				current = len(robots.groups) - 1
+				inAgents = true
+			}
+			robots.groups[current].agents = append(robots.groups[current].agents, strings.ToLower(value))
+		case "allow", "disallow":
+			inAgents = false
+			// an empty Disallow allows everything
+			if current < 0 || value == "" {
+				continue
+			}
+			robots.groups[current].rules = append(robots.groups[current].rules, newRobotsRule(key == "allow", value))
+		case "crawl-delay":
+			inAgents = false
+			if seconds, err := strconv.ParseFloat(value, 64); err == nil && current >= 0 && seconds >= 0 {
+				robots.groups[current].crawlDelay = time.Duration(seconds * float64(time.Second))
+			}
+		case "sitemap":
+			// not part of a group
+			if value != "" {
+				robots.sitemaps = append(robots.sitemaps, value)
+			}
+		default:
+			inAgents = false
+		}
+	}
+	return robots
+}
+
+// newRobotsRule compiles a path with * matching any characters and a trailing $ matching the end.
+func newRobotsRule(allow bool, path string) robotsRule {
+	rule := robotsRule{allow: allow, path: path}
+	if !strings.ContainsAny(path, "*$") {
+		return rule
+	}
+	anchored := strings.HasSuffix(path, "$")
+	parts := strings.Split(strings.TrimSuffix(path, "$"), "*")
+	for i, part := range parts {
+		parts[i] = regexp.QuoteMeta(part)
+	}
+	expr := "^" + strings.Join(parts, ".*")
+	if anchored {
+		expr += "$"
+	}
+	rule.pattern = regexp.MustCompile(expr)
+	return rule
+}
+
+// Allowed reports whether the user agent may fetch path, the escaped path and query of an url.
+// The rules of the group naming a product token of the user agent apply, compared case-insensitively,
+// those of the * group if none does.
+// The longest matching rule wins, Allow if an Allow and a Disallow rule are as long.
+func (r *RobotsTxt) Allowed(userAgent, path string) bool {
+	if r == nil || path == "/robots.txt" {
+		return true
+	}
+	if path == "" {
+		path = "/"
+	}
+	allowed, longest := true, -1
+	for _, rule := range r.rules(userAgent) {
+		if !rule.match(path) {
+			continue
+		}
+		if len(rule.path) > longest || len(rule.path) == longest && rule.allow {
+			allowed, longest = rule.allow, len(rule.path)
+		}
+	}
+	return allowed
+}
+
+// CrawlDelay returns the delay the user agent is asked to wait between requests, 0 if none.
+func (r *RobotsTxt) CrawlDelay(userAgent string) time.Duration {
+	if r == nil {
+		return 0
+	}
+	var delay time.Duration
+	for _, group := range r.matching(userAgent) {
+		if group.crawlDelay > delay {
+			delay = group.crawlDelay
+		}
+	}
+	return delay
+}
+
+// Sitemaps returns the urls of the Sitemap lines.
+func (r *RobotsTxt) Sitemaps() []string {
+	if r == nil {
+		return nil
+	}
+	return r.sitemaps
+}
+
+func (r *RobotsTxt) rules(userAgent string) []robotsRule {
+	var rules []robotsRule
+	for _, group := range r.matching(userAgent) {
+		rules = append(rules, group.rules...)
+	}
+	return rules
+}
+
+// matching returns the groups of the first product token of userAgent naming a group, those of * if none does.
+func (r *RobotsTxt) matching(userAgent string) []robotsGroup {
+	token := "*"
+	for _, product := range productTokens(userAgent) {
+		if r.names(product) {
+			token = product
+			break
+		}
+	}
+	var groups []robotsGroup
+	for _, group := range r.groups {
+		for _, agent := range group.agents {
+			if agent == token {
+				groups = append(groups, group)
+				break
+			}
+		}
+	}
+	return groups
+}
+
+func (r *RobotsTxt) names(token string) bool {
+	for _, group := range r.groups {
+		for _, agent := range group.agents {
+			if agent == token {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// productTokens returns the lowercased names of the products a User-Agent lists,
+// "googlebot" for "Mozilla/5.0 (compatible; Googlebot/2.1)" after "mozilla" and "compatible".
+func productTokens(userAgent string) []string {
+	var tokens []string
+	fields := strings.FieldsFunc(strings.ToLower(userAgent), func(r rune) bool {
+		return unicode.IsSpace(r) || strings.ContainsRune("();,", r)
+	})
+	for _, field := range fields {
+		if i := strings.IndexByte(field, '/'); i >= 0 {
+			field = field[:i]
+		}
+		if field != "" && strings.Trim(field, "abcdefghijklmnopqrstuvwxyz0123456789_-") == "" {
+			tokens = append(tokens, field)
+		}
+	}
+	return tokens
+}
+
+// RobotsError is returned by Send when robots.txt disallows the request.
+type RobotsError struct {
+	URL       *url.URL
+	UserAgent string
+}
+
+func (e *RobotsError) Error() string {
+	return fmt.Sprintf("httpclient: robots.txt disallows %s for %q", e.URL, e.UserAgent)
+}
+
+// Robots fetches the robots.txt of the origins requests are sent to and rejects the requests it disallows.
+// robots.txt is fetched within the session of the request. Everything is allowed if it is missing,
+// nothing if the server fails to serve it.
+type Robots struct {
+	Cache       Cache         // robots.txt files by origin, kept in memory if nil
+	CachePrefix string        // robots/ if empty
+	TTL         time.Duration // 24h if zero
+	ErrorTTL    time.Duration // of the robots.txt of servers failing to serve it, 1m if zero
+	// UserAgent is matched against the groups for requests without a User-Agent header,
+	// the agent of net/http if empty. Requests use the one set by AutoSelectUserAgent.
+	UserAgent string
+
+	once     sync.Once
+	memory   *memoryCache
+	mu       sync.Mutex
+	fetching map[string]*robotsCall // by origin
+	parsed   sync.Map               // origin -> *parsedRobots, the last robots.txt read from the cache
+}
+
+type robotsCall struct {
+	done   chan struct{}
+	robots *RobotsTxt
+	err    error
+}
+
+type parsedRobots struct {
+	data   []byte
+	robots *RobotsTxt
+}
+
+// Robots rejects the requests disallowed by the robots.txt of their origin with a *RobotsError.
+func (req *HttpRequest) Robots(r *Robots) *HttpRequest {
+	req.robots = r
+	return req
+}
+
+func (r *Robots) check(req *HttpRequest, target string) error {
+	u, err := url.Parse(target)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" || u.RequestURI() == "/robots.txt" {
+		return nil
+	}
+	robots, err := r.RobotsTxt(req, u)
+	if err != nil {
+		return err
+	}
+	userAgent := r.userAgent(req)
+	if !robots.Allowed(userAgent, u.RequestURI()) {
+		return &RobotsError{URL: u, UserAgent: userAgent}
+	}
+	return nil
+}
+
+func (r *Robots) userAgent(req *HttpRequest) string {
+	if userAgent := req.UserAgent(); userAgent != "" {
+		return userAgent
+	}
+	if r.UserAgent != "" {
+		return r.UserAgent
+	}
+	return defaultGoUserAgent
+}
+
+// RobotsTxt returns the robots.txt of the origin of u, fetched within the session of req if it is not cached.
+func (r *Robots) RobotsTxt(req *HttpRequest, u *url.URL) (*RobotsTxt, error) {
+	origin := u.Scheme + "://" + u.Host
+	for {
+		if data, ok := r.cached(origin); ok {
+			return r.parse(origin, data), nil
+		}
+		// a single fetch per origin at once
+		r.mu.Lock()
+		call, fetching := r.fetching[origin]
+		if !fetching {
+			if r.fetching == nil {
+				r.fetching = map[string]*robotsCall{}
+			}
+			call = &robotsCall{done: make(chan struct{})}
+			r.fetching[origin] = call
+		}
+		r.mu.Unlock()
+		if !fetching {
+			call.robots, call.err = r.fetch(req, origin)
+			r.mu.Lock()
+			delete(r.fetching, origin)
+			r.mu.Unlock()
+			close(call.done)
+			return call.robots, call.err
+		}
+		<-call.done
+		if call.err == nil {
+			return call.robots, nil
+		}
+		// the fetch failed with its own request, canceled for one: fetch it again
+	}
+}
+
+// fetch fetches the robots.txt of origin within the session of req and caches it.
+func (r *Robots) fetch(req *HttpRequest, origin string) (*RobotsTxt, error) {
+	// the credentials of req are only sent to its own origin
+	target, _ := req.buildURL()
+	from, _ := url.Parse(target)
+	resp := req.derive(from, origin+"/robots.txt").Robots(nil).Hedge(0, 0).Send()
+	code, err := resp.Code()
+	var data []byte
+	ttl := orDefaultDuration(r.TTL, defaultRobotsTTL)
+	switch {
+	case err != nil && req.ctx != nil && req.ctx.Err() != nil:
+		return nil, err
+	case err != nil || code >= http.StatusInternalServerError:
+		data, ttl = []byte(disallowAllRobots), orDefaultDuration(r.ErrorTTL, defaultRobotsErrorTTL)
+	case code >= http.StatusBadRequest:
+		data = []byte{}
+	default:
+		if data, err = resp.Body(); err != nil {
+			return nil, err
+		}
+	}
+	r.cache().Set(r.cacheKey(origin), data, ttl)
+	return r.parse(origin, data), nil
+}
+
+// parse parses the robots.txt of origin, unless it is the one parsed last.
+func (r *Robots) parse(origin string, data []byte) *RobotsTxt {
+	if value, ok := r.parsed.Load(origin); ok {
+		if parsed := value.(*parsedRobots); bytes.Equal(parsed.data, data) {
+			return parsed.robots
+		}
+	}
+	robots := ParseRobotsTxt(data)
+	r.parsed.Store(origin, &parsedRobots{data: data, robots: robots})
+	return robots
+}
+
+func (r *Robots) cached(origin string) ([]byte, bool) {
+	value, found := r.cache().Get(r.cacheKey(origin))
+	data, ok := value.([]byte)
+	return data, found && ok
+}
+
+func (r *Robots) cacheKey(origin string) string {
+	if r.CachePrefix != "" {
+		return r.CachePrefix + origin
+	}
+	return defaultRobotsPrefix + origin
+}
+
+func (r *Robots) cache() Cache {
+	if r.Cache != nil {
+		return r.Cache
+	}
+	r.once.Do(func() { r.memory = &memoryCache{items: map[string]memoryItem{}} })
+	return r.memory
+}
+
+// memoryCache is a Cache in memory, expired items are dropped when they are read.
+type memoryCache struct {
+	mu    sync.Mutex
+	items map[string]memoryItem
+}
+
+type memoryItem struct {
+	value    interface{}
+	deadline time.Time
+}
+
+func (c *memoryCache) Get(key string) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	item, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(item.deadline) {
+		delete(c.items, key)
+		return nil, false
+	}
+	return item.value, true
+}
+
+func (c *memoryCache) Set(key string, value interface{}, exp time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.items[key] = memoryItem{value: value, deadline: time.Now().Add(exp)}
+}
@@ -0,0 +1,65 @@
+package httpclient
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRobotsUserAgentGroups(t *testing.T) {
+	robots := ParseRobotsTxt([]byte("User-agent: Googlebot\nDisallow: /google\n\nUser-agent: *\nDisallow: /all\n"))
+	tests := []struct {
+		userAgent string
+		group     string
+	}{
+		{"Googlebot", "/google"},
+		{"googlebot/2.1", "/google"},
+		{"Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)", "/google"},
+		{"NotGooglebot/1.0", "/all"},
+		{"Googlebot-News", "/all"},
+		{"testbot", "/all"},
+	}
+	for _, test := range tests {
+		for _, path := range []string{"/google", "/all"} {
+			if allowed := robots.Allowed(test.userAgent, path); allowed == (path == test.group) {
+				t.Errorf("%q: Allowed(%s) = %v, want the rules of the group disallowing %s", test.userAgent, path, allowed, test.group)
+			}
+		}
+	}
+}
+
+func TestRobotsFetchOnce(t *testing.T) {
+	var fetches int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetches, 1)
+		time.Sleep(20 * time.Millisecond)
+		w.Write([]byte("User-agent: *\nDisallow: /private\n"))
+	}))
+	defer server.Close()
+	u, _ := url.Parse(server.URL + "/page")
+	robots := &Robots{}
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			txt, err := robots.RobotsTxt(New(&http.Client{}).Get(u.String()), u)
+			if err != nil {
+				t.Error(err)
+			} else if txt.Allowed("testbot", "/private") {
+				t.Error("/private allowed")
+			}
+		}()
+	}
+	wg.Wait()
+	if fetches != 1 {
+		t.Errorf("robots.txt fetched %d times, want once", fetches)
+	}
+	if len(robots.fetching) != 0 {
+		t.Errorf("%d fetches kept after they ended", len(robots.fetching))
+	}
+}
@@ -0,0 +1,83 @@
+package httpclient
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Signer signs the final request, it is called after the body has been encoded
+// and before the request is sent, and again for every retry.
+// body is the buffered request body, a signer may replace request.Body with an encoded form of it.
+type Signer interface {
+	Sign(request *http.Request, body []byte) error
+}
+
+// SignerFunc adapts a function to Signer.
+type SignerFunc func(request *http.Request, body []byte) error
+
+func (f SignerFunc) Sign(request *http.Request, body []byte) error {
+	return f(request, body)
+}
+
+func (req *HttpRequest) Sign(signer Signer) *HttpRequest {
+	req.signer = signer
+	return req
+}
+
+func hashHex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// HMACSigner signs requests with HMAC-SHA256 over
+//
+//	METHOD \n REQUEST-URI \n TIMESTAMP \n name:value of each SignedHeaders \n hex(sha256(body))
+//
+// The unix timestamp is sent in TimestampHeader and the hex signature in Header.
+type HMACSigner struct {
+	Secret          []byte
+	KeyID           string   // sent in KeyIDHeader if set
+	KeyIDHeader     string   // X-Key-Id if empty
+	Header          string   // X-Signature if empty
+	TimestampHeader string   // X-Timestamp if empty
+	SignedHeaders   []string // extra headers covered by the signature
+	Now             func() time.Time
+}
+
+func (s *HMACSigner) Sign(request *http.Request, body []byte) error {
+	now := time.Now
+	if s.Now != nil {
+		now = s.Now
+	}
+	timestamp := strconv.FormatInt(now().Unix(), 10)
+	request.Header.Set(orDefault(s.TimestampHeader, "X-Timestamp"), timestamp)
+	if s.KeyID != "" {
+		request.Header.Set(orDefault(s.KeyIDHeader, "X-Key-Id"), s.KeyID)
+	}
+	lines := []string{request.Method, request.URL.RequestURI(), timestamp}
+	for _, name := range s.SignedHeaders {
+		lines = append(lines, strings.ToLower(name)+":"+strings.TrimSpace(request.Header.Get(name)))
+	}
+	lines = append(lines, hashHex(body))
+	signature := hmacSHA256(s.Secret, strings.Join(lines, "\n"))
+	request.Header.Set(orDefault(s.Header, "X-Signature"), hex.EncodeToString(signature))
+	return nil
+}
+
+func orDefault(s, defaultValue string) string {
+	if s == "" {
+		return defaultValue
+	}
+	return s
+}
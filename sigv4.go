@@ -0,0 +1,221 @@
+package httpclient
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	sigV4Algorithm        = "AWS4-HMAC-SHA256"
+	sigV4UnsignedPayload  = "UNSIGNED-PAYLOAD"
+	sigV4StreamingPayload = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD"
+	sigV4TimeFormat       = "20060102T150405Z"
+	sigV4DateFormat       = "20060102"
+)
+
+// headers not covered by the signature, they may be changed on the way
+var sigV4IgnoredHeaders = map[string]bool{
+	"authorization":   true,
+	"user-agent":      true,
+	"x-amzn-trace-id": true,
+	"expect":          true,
+}
+
+// AWSSigner signs requests with AWS Signature Version 4.
+//
+// The payload is hashed unless UnsignedPayload is set. With ChunkSize the body is sent
+// as aws-chunked with a signature per chunk (STREAMING-AWS4-HMAC-SHA256-PAYLOAD), as S3 accepts for uploads.
+type AWSSigner struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	Region          string
+	Service         string
+	UnsignedPayload bool
+	ChunkSize       int // at least 8 KiB for S3
+	Now             func() time.Time
+}
+
+func (s *AWSSigner) Sign(request *http.Request, body []byte) error {
+	now := time.Now
+	if s.Now != nil {
+		now = s.Now
+	}
+	t := now().UTC()
+	amzDate := t.Format(sigV4TimeFormat)
+	scope := strings.Join([]string{t.Format(sigV4DateFormat), s.Region, s.Service, "aws4_request"}, "/")
+
+	request.Header.Set("X-Amz-Date", amzDate)
+	if s.SessionToken != "" {
+		request.Header.Set("X-Amz-Security-Token", s.SessionToken)
+	}
+	payloadHash := hashHex(body)
+	switch {
+	case s.ChunkSize > 0:
+		payloadHash = sigV4StreamingPayload
+		request.Header.Set("Content-Encoding", "aws-chunked")
+		request.Header.Set("X-Amz-Decoded-Content-Length", strconv.Itoa(len(body)))
+		request.ContentLength = chunkedLength(len(body), s.ChunkSize)
+		request.Header.Set("Content-Length", strconv.FormatInt(request.ContentLength, 10))
+	case s.UnsignedPayload:
+		payloadHash = sigV4UnsignedPayload
+	}
+	if s.Service == "s3" || s.ChunkSize > 0 || s.UnsignedPayload {
+		request.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	}
+
+	signedHeaders, canonicalHeaders := sigV4Headers(request)
+	canonicalRequest := strings.Join([]string{
+		request.Method,
+		s.canonicalURI(request.URL),
+		sigV4Query(request.URL),
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	key := s.signingKey(t)
+	signature := hex.EncodeToString(hmacSHA256(key, strings.Join([]string{
+		sigV4Algorithm, amzDate, scope, hashHex([]byte(canonicalRequest)),
+	}, "\n")))
+	request.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		sigV4Algorithm, s.AccessKeyID, scope, signedHeaders, signature))
+
+	if s.ChunkSize > 0 {
+		// Content-Length is sent from request.ContentLength
+		request.Header.Del("Content-Length")
+		chunked := s.chunkedBody(body, key, amzDate, scope, signature)
+		request.Body = ioutil.NopCloser(bytes.NewReader(chunked))
+		request.GetBody = func() (io.ReadCloser, error) {
+			return ioutil.NopCloser(bytes.NewReader(chunked)), nil
+		}
+	}
+	return nil
+}
+
+func (s *AWSSigner) signingKey(t time.Time) []byte {
+	key := hmacSHA256([]byte("AWS4"+s.SecretAccessKey), t.Format(sigV4DateFormat))
+	key = hmacSHA256(key, s.Region)
+	key = hmacSHA256(key, s.Service)
+	return hmacSHA256(key, "aws4_request")
+}
+
+// canonicalURI encodes the escaped path once more, except for S3 which signs it as sent.
+func (s *AWSSigner) canonicalURI(u *url.URL) string {
+	path := u.EscapedPath()
+	if path == "" {
+		return "/"
+	}
+	if s.Service == "s3" {
+		return path
+	}
+	return awsURIEncode(path, false)
+}
+
+func sigV4Query(u *url.URL) string {
+	query := u.Query()
+	var pairs []string
+	for k, vs := range query {
+		for _, v := range vs {
+			pairs = append(pairs, awsURIEncode(k, true)+"="+awsURIEncode(v, true))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+func sigV4Headers(request *http.Request) (signed string, canonical string) {
+	host := request.Host
+	if host == "" {
+		host = request.URL.Host
+	}
+	values := map[string]string{"host": host}
+	for k, vs := range request.Header {
+		name := strings.ToLower(k)
+		if sigV4IgnoredHeaders[name] {
+			continue
+		}
+		trimmed := make([]string, len(vs))
+		for i, v := range vs {
+			trimmed[i] = strings.Join(strings.Fields(v), " ")
+		}
+		values[name] = strings.Join(trimmed, ",")
+	}
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	for _, name := range names {
+		b.WriteString(name)
+		b.WriteByte(':')
+		b.WriteString(values[name])
+		b.WriteByte('\n')
+	}
+	return strings.Join(names, ";"), b.String()
+}
+
+// awsURIEncode escapes everything but the RFC 3986 unreserved characters.
+func awsURIEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func chunkedLength(size int, chunkSize int) int64 {
+	// hex(size);chunk-signature=<64 hex>\r\n<data>\r\n
+	chunkLength := func(n int) int64 {
+		return int64(len(strconv.FormatInt(int64(n), 16)) + len(";chunk-signature=") + 64 + 2 + n + 2)
+	}
+	var total int64
+	for ; size >= chunkSize; size -= chunkSize {
+		total += chunkLength(chunkSize)
+	}
+	if size > 0 {
+		total += chunkLength(size)
+	}
+	return total + chunkLength(0)
+}
+
+func (s *AWSSigner) chunkedBody(body []byte, key []byte, amzDate, scope, seed string) []byte {
+	emptyHash := hashHex(nil)
+	var buf bytes.Buffer
+	previous := seed
+	for {
+		n := s.ChunkSize
+		if n > len(body) {
+			n = len(body)
+		}
+		chunk := body[:n]
+		body = body[n:]
+		previous = hex.EncodeToString(hmacSHA256(key, strings.Join([]string{
+			sigV4Algorithm + "-PAYLOAD", amzDate, scope, previous, emptyHash, hashHex(chunk),
+		}, "\n")))
+		fmt.Fprintf(&buf, "%x;chunk-signature=%s\r\n", n, previous)
+		buf.Write(chunk)
+		buf.WriteString("\r\n")
+		if n == 0 {
+			return buf.Bytes()
+		}
+	}
+}
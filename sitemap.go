@@ -0,0 +1,176 @@
+package httpclient
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/xml"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// how many levels of indexes of sitemaps are expanded
+	maxSitemapDepth = 5
+	// the size limit of an uncompressed sitemap
+	maxSitemapSize = 50 << 20
+)
+
+var errSitemapTooLarge = errors.New("httpclient: sitemap larger than 50MB uncompressed")
+
+// Sitemap is the content of a sitemap, with that of the sitemaps it indexes.
+type Sitemap struct {
+	URLs     []SitemapURL
+	Sitemaps []SitemapURL // the sitemaps indexed, recursively
+}
+
+// SitemapURL is an url of a sitemap or a sitemap of an index.
+type SitemapURL struct {
+	Loc        string
+	LastMod    time.Time
+	ChangeFreq string
+	Priority   float64 // 0.5 if missing
+}
+
+type sitemapDocument struct {
+	XMLName  xml.Name
+	URLs     []sitemapEntry `xml:"url"`
+	Sitemaps []sitemapEntry `xml:"sitemap"`
+}
+
+type sitemapEntry struct {
+	Loc        string `xml:"loc"`
+	LastMod    string `xml:"lastmod"`
+	ChangeFreq string `xml:"changefreq"`
+	Priority   string `xml:"priority"`
+}
+
+func (e sitemapEntry) url() SitemapURL {
+	u := SitemapURL{Loc: strings.TrimSpace(e.Loc), ChangeFreq: strings.TrimSpace(e.ChangeFreq), Priority: 0.5}
+	u.LastMod, _ = parseFeedTime(e.LastMod)
+	if priority, err := strconv.ParseFloat(strings.TrimSpace(e.Priority), 64); err == nil {
+		u.Priority = priority
+	}
+	return u
+}
+
+// Sitemap parses a urlset or a sitemapindex, gzipped or not. The sitemaps of an index are fetched
+// within the session of the request and expanded, up to 5 levels deep. Their first error is returned
+// with what could be read.
+func (resp *HttpResponse) Sitemap() (*Sitemap, error) {
+	if resp.err != nil {
+		return nil, resp.err
+	}
+	doc, err := resp.sitemapDocument()
+	if err != nil {
+		return nil, err
+	}
+	sitemap := &Sitemap{}
+	var firstErr error
+	resp.expandSitemap(sitemap, doc, map[string]bool{}, 0, &firstErr)
+	return sitemap, firstErr
+}
+
+func (resp *HttpResponse) sitemapDocument() (*sitemapDocument, error) {
+	body, err := gunzipped(resp.body)
+	if err != nil {
+		return nil, err
+	}
+	decoder, err := resp.xmlDecoder(body)
+	if err != nil {
+		return nil, err
+	}
+	var doc sitemapDocument
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, err
+	}
+	return &doc, nil
+}
+
+func (resp *HttpResponse) expandSitemap(sitemap *Sitemap, doc *sitemapDocument, seen map[string]bool, depth int, firstErr *error) {
+	for _, entry := range doc.URLs {
+		sitemap.URLs = append(sitemap.URLs, entry.url())
+	}
+	for _, entry := range doc.Sitemaps {
+		index := entry.url()
+		if index.Loc == "" || seen[index.Loc] {
+			continue
+		}
+		seen[index.Loc] = true
+		sitemap.Sitemaps = append(sitemap.Sitemaps, index)
+		if depth >= maxSitemapDepth {
+			continue
+		}
+		var req *HttpRequest
+		if resp.request != nil {
+			req = resp.request.derive(resp.url, index.Loc)
+		} else {
+			req = NewHttpRequest(http.DefaultClient).Get().Url(index.Loc)
+		}
+		child := req.Send()
+		if err := child.CheckStatus(); err != nil {
+			if *firstErr == nil {
+				*firstErr = err
+			}
+			continue
+		}
+		childDoc, err := child.sitemapDocument()
+		if err != nil {
+			if *firstErr == nil {
+				*firstErr = err
+			}
+			continue
+		}
+		child.expandSitemap(sitemap, childDoc, seen, depth+1, firstErr)
+	}
+}
+
+// gunzipped returns data decompressed if it is gzipped, up to maxSitemapSize either way.
+func gunzipped(data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, []byte{0x1f, 0x8b}) {
+		if len(data) > maxSitemapSize {
+			return nil, errSitemapTooLarge
+		}
+		return data, nil
+	}
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	data, err = ioutil.ReadAll(io.LimitReader(reader, maxSitemapSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxSitemapSize {
+		return nil, errSitemapTooLarge
+	}
+	return data, nil
+}
+
+// xmlDecoder decodes XML from body in the encoding of the response, detected as AutoDecode does if it is not set.
+func (resp *HttpResponse) xmlDecoder(body []byte) (*xml.Decoder, error) {
+	enc := resp.encoding
+	if enc == nil {
+		enc, _, _ = detectCharset(body, resp.header.Get("Content-Type"))
+	}
+	if enc != nil {
+		decoded, err := enc.NewDecoder().Bytes(body)
+		if err != nil {
+			return nil, err
+		}
+		body = decoded
+	}
+	decoder := xml.NewDecoder(bytes.NewReader(body))
+	// already decoded whatever the declaration says
+	decoder.CharsetReader = func(_ string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}
+	decoder.Strict = false
+	decoder.Entity = xml.HTMLEntity
+	return decoder, nil
+}
@@ -0,0 +1,68 @@
+package httpclient
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestSitemapIndexCredentials(t *testing.T) {
+	var mu sync.Mutex
+	authorized := map[string]bool{}
+	urlset := func(loc string) string {
+		return `<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9"><url><loc>` + loc + `</loc></url></urlset>`
+	}
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		authorized["other origin"] = r.Header.Get("Authorization") != ""
+		mu.Unlock()
+		w.Write([]byte(urlset("http://other/page")))
+	}))
+	defer other.Close()
+	// localhost is another host than the 127.0.0.1 of the index
+	otherURL := strings.Replace(other.URL, "127.0.0.1", "localhost", 1)
+	var index *httptest.Server
+	index = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/pages.xml" {
+			mu.Lock()
+			authorized["same origin"] = r.Header.Get("Authorization") != ""
+			mu.Unlock()
+			w.Write([]byte(urlset("http://same/page")))
+			return
+		}
+		w.Write([]byte(`<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">` +
+			`<sitemap><loc>` + index.URL + `/pages.xml</loc></sitemap><sitemap><loc>` + otherURL + `/pages.xml</loc></sitemap></sitemapindex>`))
+	}))
+	defer index.Close()
+
+	sitemap, err := New(&http.Client{}).Get(index.URL+"/sitemap.xml").BasicAuth("user", "password").Send().Sitemap()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var locs []string
+	for _, u := range sitemap.URLs {
+		locs = append(locs, u.Loc)
+	}
+	if want := []string{"http://same/page", "http://other/page"}; !reflect.DeepEqual(locs, want) {
+		t.Errorf("urls %q, want %q", locs, want)
+	}
+	if want := map[string]bool{"same origin": true, "other origin": false}; !reflect.DeepEqual(authorized, want) {
+		t.Errorf("Authorization sent %v, want %v", authorized, want)
+	}
+}
+
+func TestSitemapTooLarge(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">`))
+		w.Write(bytes.Repeat([]byte(" "), maxSitemapSize))
+		w.Write([]byte(`</urlset>`))
+	}))
+	defer server.Close()
+	if _, err := New(&http.Client{}).Get(server.URL).Send().Sitemap(); err != errSitemapTooLarge {
+		t.Errorf("err = %v, want errSitemapTooLarge", err)
+	}
+}
@@ -0,0 +1,91 @@
+package httpclient
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+var pathParamPattern = regexp.MustCompile(`\{([^{}/]+)\}`)
+
+func (req *HttpRequest) buildURL() (string, error) {
+	target := joinURL(req.baseURL, req.url)
+	if req.path != "" {
+		path, err := expandPath(req.path, req.pathParams)
+		if err != nil {
+			return "", err
+		}
+		target = joinURL(target, path)
+	}
+	if len(req.querys) == 0 {
+		return target, nil
+	}
+	return appendQuery(target, string(encodeQuery(req.querys, req.encoding))), nil
+}
+
+func expandPath(template string, params map[string]string) (string, error) {
+	var err error
+	path := pathParamPattern.ReplaceAllStringFunc(template, func(holder string) string {
+		name := holder[1 : len(holder)-1]
+		v, ok := params[name]
+		if !ok {
+			if err == nil {
+				err = fmt.Errorf("httpclient: missing path param %q in %q", name, template)
+			}
+			return holder
+		}
+		return url.PathEscape(v)
+	})
+	return path, err
+}
+
+// joinURL joins ref to base unless ref is already an absolute url,
+// the queries of both sides are kept.
+func joinURL(base, ref string) string {
+	if base == "" {
+		return ref
+	}
+	if ref == "" {
+		return base
+	}
+	if u, err := url.Parse(ref); err == nil && u.IsAbs() {
+		return ref
+	}
+	basePath, baseQuery, baseFragment := splitURL(base)
+	refPath, refQuery, refFragment := splitURL(ref)
+	joined := basePath
+	if refPath != "" {
+		joined = strings.TrimRight(basePath, "/") + "/" + strings.TrimLeft(refPath, "/")
+	}
+	for _, query := range []string{baseQuery, refQuery} {
+		if query != "" {
+			joined = appendQuery(joined, query)
+		}
+	}
+	if refFragment != "" {
+		return joined + refFragment
+	}
+	return joined + baseFragment
+}
+
+// splitURL splits rawURL into its path part, raw query and fragment (with leading '#').
+func splitURL(rawURL string) (path, query, fragment string) {
+	if i := strings.IndexByte(rawURL, '#'); i >= 0 {
+		rawURL, fragment = rawURL[:i], rawURL[i:]
+	}
+	if i := strings.IndexByte(rawURL, '?'); i >= 0 {
+		rawURL, query = rawURL[:i], rawURL[i+1:]
+	}
+	return rawURL, query, fragment
+}
+
+// appendQuery merges an encoded query into the query already present in rawURL.
+func appendQuery(rawURL string, query string) string {
+	path, current, fragment := splitURL(rawURL)
+	current = strings.TrimRight(current, "&")
+	if current == "" {
+		return path + "?" + query + fragment
+	}
+	return path + "?" + current + "&" + query + fragment
+}
@@ -0,0 +1,323 @@
+package httpclient
+
+import (
+	"encoding"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ValuesMarshaler is implemented by types that encode themselves into
+// query, form or header values.
+type ValuesMarshaler interface {
+	MarshalValues() ([]string, error)
+}
+
+var (
+	timeType            = reflect.TypeOf(time.Time{})
+	valuesMarshalerType = reflect.TypeOf((*ValuesMarshaler)(nil)).Elem()
+	textMarshalerType   = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// QueryStruct appends the fields of v to the query, see structValues for the tag format.
+func (req *HttpRequest) QueryStruct(v interface{}) *HttpRequest {
+	pairs, err := structValues(v, "url")
+	if err != nil {
+		req.err = err
+		return req
+	}
+	req.querys = append(req.querys, pairs...)
+	return req
+}
+
+// FormStruct appends the fields of v to the form body, using the same tags as QueryStruct.
+func (req *HttpRequest) FormStruct(v interface{}) *HttpRequest {
+	pairs, err := structValues(v, "url")
+	if err != nil {
+		req.err = err
+		return req
+	}
+	if req.params == nil {
+		req.params = make(map[string][]string)
+		req.Head("Content-Type", "application/x-www-form-urlencoded")
+	}
+	for _, kv := range pairs {
+		req.params[kv[0]] = append(req.params[kv[0]], kv[1])
+	}
+	return req
+}
+
+// HeaderStruct sets headers from the fields of v tagged with `header:"Name,omitempty"`.
+func (req *HttpRequest) HeaderStruct(v interface{}) *HttpRequest {
+	pairs, err := structValues(v, "header")
+	if err != nil {
+		req.err = err
+		return req
+	}
+	header := http.Header{}
+	for _, kv := range pairs {
+		header.Add(kv[0], kv[1])
+	}
+	for k, vs := range header {
+		req.header[k] = vs
+	}
+	return req
+}
+
+// structValues flattens a struct into ordered key/value pairs.
+//
+// Fields are named by the tag, e.g. `url:"page,omitempty"`, and fall back to the field name.
+// Supported options are omitempty, comma (join slices with ','), unix and unixmilli (for time.Time).
+// Time fields are formatted with the `layout:"..."` tag or RFC3339. Embedded structs are inlined
+// and nested structs are prefixed as parent[child].
+func structValues(v interface{}, tag string) ([][]string, error) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return nil, nil
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("httpclient: expect a struct, got %s", rv.Kind())
+	}
+	var pairs [][]string
+	err := appendStruct(&pairs, rv, "", tag)
+	return pairs, err
+}
+
+type tagOptions []string
+
+func (opts tagOptions) has(name string) bool {
+	for _, opt := range opts {
+		if opt == name {
+			return true
+		}
+	}
+	return false
+}
+
+func parseTag(tag string) (string, tagOptions) {
+	parts := strings.Split(tag, ",")
+	return parts[0], tagOptions(parts[1:])
+}
+
+func appendStruct(pairs *[][]string, rv reflect.Value, prefix string, tag string) error {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		name, opts := parseTag(field.Tag.Get(tag))
+		if name == "-" {
+			continue
+		}
+		fv := rv.Field(i)
+		if field.Anonymous && name == "" {
+			embedded := fv
+			for embedded.Kind() == reflect.Ptr && !embedded.IsNil() {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct && !isScalar(embedded) {
+				if err := appendStruct(pairs, embedded, prefix, tag); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		if field.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		if prefix != "" {
+			name = prefix + "[" + name + "]"
+		}
+		if !readable(fv) {
+			continue
+		}
+		if opts.has("omitempty") && isEmptyValue(fv) {
+			continue
+		}
+		if err := appendValue(pairs, fv, name, opts, field.Tag.Get("layout"), tag); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func appendValue(pairs *[][]string, fv reflect.Value, name string, opts tagOptions, layout string, tag string) error {
+	for fv.Kind() == reflect.Ptr || fv.Kind() == reflect.Interface {
+		if fv.IsNil() {
+			return nil
+		}
+		if fv.Type().Implements(valuesMarshalerType) {
+			break
+		}
+		fv = fv.Elem()
+	}
+	if !readable(fv) {
+		return nil
+	}
+	if m, ok := marshaler(fv); ok {
+		vs, err := m.MarshalValues()
+		if err != nil {
+			return err
+		}
+		for _, v := range vs {
+			*pairs = append(*pairs, []string{name, v})
+		}
+		return nil
+	}
+	switch {
+	case fv.Kind() == reflect.Struct && !isScalar(fv):
+		return appendStruct(pairs, fv, name, tag)
+	case (fv.Kind() == reflect.Slice || fv.Kind() == reflect.Array) && fv.Type().Elem().Kind() != reflect.Uint8:
+		vs := make([]string, 0, fv.Len())
+		for i := 0; i < fv.Len(); i++ {
+			elem := fv.Index(i)
+			for elem.Kind() == reflect.Ptr || elem.Kind() == reflect.Interface {
+				if elem.IsNil() {
+					break
+				}
+				elem = elem.Elem()
+			}
+			if elem.Kind() == reflect.Ptr || elem.Kind() == reflect.Interface || !readable(elem) {
+				continue
+			}
+			s, err := formatScalar(elem, opts, layout)
+			if err != nil {
+				return err
+			}
+			vs = append(vs, s)
+		}
+		if opts.has("comma") {
+			*pairs = append(*pairs, []string{name, strings.Join(vs, ",")})
+			return nil
+		}
+		for _, v := range vs {
+			*pairs = append(*pairs, []string{name, v})
+		}
+		return nil
+	}
+	s, err := formatScalar(fv, opts, layout)
+	if err != nil {
+		return err
+	}
+	*pairs = append(*pairs, []string{name, s})
+	return nil
+}
+
+func marshaler(fv reflect.Value) (ValuesMarshaler, bool) {
+	if !fv.CanInterface() {
+		return nil, false
+	}
+	if fv.Type().Implements(valuesMarshalerType) {
+		return fv.Interface().(ValuesMarshaler), true
+	}
+	if fv.CanAddr() && fv.Addr().Type().Implements(valuesMarshalerType) {
+		return fv.Addr().Interface().(ValuesMarshaler), true
+	}
+	return nil, false
+}
+
+// readable reports whether fv can be encoded. Fields promoted through unexported embedded structs
+// can only be read by kind, so those needing a marshaler or their time.Time value are skipped.
+func readable(fv reflect.Value) bool {
+	if fv.CanInterface() {
+		return true
+	}
+	t := fv.Type()
+	if t == timeType || t.Implements(textMarshalerType) || t.Implements(valuesMarshalerType) ||
+		reflect.PtrTo(t).Implements(textMarshalerType) || reflect.PtrTo(t).Implements(valuesMarshalerType) {
+		return false
+	}
+	switch fv.Kind() {
+	case reflect.String, reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64, reflect.Slice, reflect.Array, reflect.Struct, reflect.Ptr, reflect.Interface:
+		return true
+	}
+	return false
+}
+
+// isScalar reports whether a struct value is encoded as a single value rather than field by field.
+func isScalar(fv reflect.Value) bool {
+	t := fv.Type()
+	return t == timeType || t.Implements(textMarshalerType) || t.Implements(valuesMarshalerType) ||
+		reflect.PtrTo(t).Implements(textMarshalerType) || reflect.PtrTo(t).Implements(valuesMarshalerType)
+}
+
+func formatScalar(fv reflect.Value, opts tagOptions, layout string) (string, error) {
+	if fv.Type() == timeType {
+		t := fv.Interface().(time.Time)
+		switch {
+		case opts.has("unix"):
+			return strconv.FormatInt(t.Unix(), 10), nil
+		case opts.has("unixmilli"):
+			return strconv.FormatInt(t.UnixNano()/int64(time.Millisecond), 10), nil
+		case layout != "":
+			return t.Format(layout), nil
+		}
+		return t.Format(time.RFC3339), nil
+	}
+	if m, ok := marshaler(fv); ok {
+		vs, err := m.MarshalValues()
+		if err != nil {
+			return "", err
+		}
+		return strings.Join(vs, ","), nil
+	}
+	var tm encoding.TextMarshaler
+	if fv.Type().Implements(textMarshalerType) {
+		tm = fv.Interface().(encoding.TextMarshaler)
+	} else if fv.CanAddr() && fv.Addr().Type().Implements(textMarshalerType) {
+		tm = fv.Addr().Interface().(encoding.TextMarshaler)
+	}
+	if tm != nil {
+		text, err := tm.MarshalText()
+		return string(text), err
+	}
+	switch fv.Kind() {
+	case reflect.String:
+		return fv.String(), nil
+	case reflect.Bool:
+		return strconv.FormatBool(fv.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(fv.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(fv.Uint(), 10), nil
+	case reflect.Float32:
+		return strconv.FormatFloat(fv.Float(), 'f', -1, 32), nil
+	case reflect.Float64:
+		return strconv.FormatFloat(fv.Float(), 'f', -1, 64), nil
+	case reflect.Slice:
+		// []byte
+		return string(fv.Bytes()), nil
+	}
+	return fmt.Sprint(fv.Interface()), nil
+}
+
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	case reflect.Struct:
+		if v.Type() == timeType {
+			return v.Interface().(time.Time).IsZero()
+		}
+	}
+	return false
+}
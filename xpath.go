@@ -0,0 +1,478 @@
+package httpclient
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strings"
+
+	"github.com/antchfx/xpath"
+	"golang.org/x/net/html"
+)
+
+// Node is a node of an HTML or XML document selected by XPath.
+type Node struct {
+	nav xpath.NodeNavigator
+}
+
+// NodeSet are the nodes selected by an XPath expression in document order.
+type NodeSet []*Node
+
+// Name returns the local name of an element or attribute.
+func (n *Node) Name() string {
+	return n.nav.LocalName()
+}
+
+// Text returns the text of the node and its descendants, or the value of an attribute.
+func (n *Node) Text() string {
+	return n.nav.Value()
+}
+
+// Attr returns the value of the attribute name.
+func (n *Node) Attr(name string) (string, bool) {
+	attr := n.nav.Copy()
+	for attr.MoveToNextAttribute() {
+		if attr.LocalName() == name {
+			return attr.Value(), true
+		}
+	}
+	return "", false
+}
+
+// XPath selects nodes relative to n.
+func (n *Node) XPath(expr string) (NodeSet, error) {
+	return selectNodes(n.nav, expr)
+}
+
+// First returns the first node, nil if the set is empty.
+func (s NodeSet) First() *Node {
+	if len(s) == 0 {
+		return nil
+	}
+	return s[0]
+}
+
+// Text returns the text of the first node, empty if the set is empty.
+func (s NodeSet) Text() string {
+	if len(s) == 0 {
+		return ""
+	}
+	return s[0].Text()
+}
+
+// Texts returns the text of every node.
+func (s NodeSet) Texts() []string {
+	texts := make([]string, len(s))
+	for i, n := range s {
+		texts[i] = n.Text()
+	}
+	return texts
+}
+
+// Attrs returns the attribute name of the nodes that have it.
+func (s NodeSet) Attrs(name string) []string {
+	var values []string
+	for _, n := range s {
+		if v, ok := n.Attr(name); ok {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+func selectNodes(root xpath.NodeNavigator, expr string) (NodeSet, error) {
+	compiled, err := xpath.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	var nodes NodeSet
+	iter := compiled.Select(root)
+	for iter.MoveNext() {
+		nodes = append(nodes, &Node{nav: iter.Current().Copy()})
+	}
+	return nodes, nil
+}
+
+// XPath selects nodes of the HTML of the response, decoded as by HTMLDetectedEncode.
+func (resp *HttpResponse) XPath(expr string) (NodeSet, error) {
+	doc, err := resp.HTMLDetectedEncode()
+	if err != nil {
+		return nil, err
+	}
+	root := doc.Nodes[0]
+	return selectNodes(&htmlNavigator{root: root, curr: root, attr: -1}, expr)
+}
+
+// XMLDocument is a parsed XML response.
+type XMLDocument struct {
+	root       *xmlNode
+	namespaces map[string]string // namespace url -> prefix used in expressions
+}
+
+// XML parses the body as XML, decoded with the encoding of the response or the charset detected as AutoDecode does.
+// namespaces maps the prefixes used in XPath expressions to namespace urls, elements and
+// attributes of a namespace in it are matched by that prefix whatever prefix the document uses.
+func (resp *HttpResponse) XML(namespaces map[string]string) (*XMLDocument, error) {
+	if resp.err != nil {
+		return nil, resp.err
+	}
+	body, err := resp.decodedBody()
+	if err != nil {
+		return nil, err
+	}
+	root, err := parseXML(body)
+	if err != nil {
+		return nil, err
+	}
+	doc := &XMLDocument{root: root, namespaces: make(map[string]string, len(namespaces))}
+	for prefix, url := range namespaces {
+		doc.namespaces[url] = prefix
+	}
+	return doc, nil
+}
+
+// XPath selects nodes of the document.
+func (doc *XMLDocument) XPath(expr string) (NodeSet, error) {
+	return selectNodes(&xmlNavigator{doc: doc, curr: doc.root, attr: -1}, expr)
+}
+
+// htmlNavigator walks the nodes of golang.org/x/net/html, as parsed by goquery.
+type htmlNavigator struct {
+	root, curr *html.Node
+	attr       int // index of the current attribute, -1 on the node itself
+}
+
+func (h *htmlNavigator) NodeType() xpath.NodeType {
+	switch h.curr.Type {
+	case html.CommentNode:
+		return xpath.CommentNode
+	case html.TextNode:
+		return xpath.TextNode
+	case html.DocumentNode, html.DoctypeNode:
+		return xpath.RootNode
+	}
+	if h.attr != -1 {
+		return xpath.AttributeNode
+	}
+	return xpath.ElementNode
+}
+
+func (h *htmlNavigator) LocalName() string {
+	if h.attr != -1 {
+		return h.curr.Attr[h.attr].Key
+	}
+	return h.curr.Data
+}
+
+func (h *htmlNavigator) Prefix() string {
+	return ""
+}
+
+func (h *htmlNavigator) Value() string {
+	switch {
+	case h.attr != -1:
+		return h.curr.Attr[h.attr].Val
+	case h.curr.Type == html.TextNode, h.curr.Type == html.CommentNode:
+		return h.curr.Data
+	}
+	var b strings.Builder
+	var collect func(n *html.Node)
+	collect = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			b.WriteString(n.Data)
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			collect(child)
+		}
+	}
+	collect(h.curr)
+	return b.String()
+}
+
+func (h *htmlNavigator) Copy() xpath.NodeNavigator {
+	n := *h
+	return &n
+}
+
+func (h *htmlNavigator) MoveToRoot() {
+	h.curr, h.attr = h.root, -1
+}
+
+func (h *htmlNavigator) MoveToParent() bool {
+	if h.attr != -1 {
+		h.attr = -1
+		return true
+	}
+	if h.curr.Parent == nil {
+		return false
+	}
+	h.curr = h.curr.Parent
+	return true
+}
+
+func (h *htmlNavigator) MoveToNextAttribute() bool {
+	if h.attr >= len(h.curr.Attr)-1 {
+		return false
+	}
+	h.attr++
+	return true
+}
+
+func (h *htmlNavigator) MoveToChild() bool {
+	if h.attr != -1 || h.curr.FirstChild == nil {
+		return false
+	}
+	h.curr = h.curr.FirstChild
+	return true
+}
+
+func (h *htmlNavigator) MoveToFirst() bool {
+	if h.attr != -1 || h.curr.PrevSibling == nil {
+		return false
+	}
+	for h.curr.PrevSibling != nil {
+		h.curr = h.curr.PrevSibling
+	}
+	return true
+}
+
+func (h *htmlNavigator) MoveToNext() bool {
+	if h.attr != -1 || h.curr.NextSibling == nil {
+		return false
+	}
+	h.curr = h.curr.NextSibling
+	return true
+}
+
+func (h *htmlNavigator) MoveToPrevious() bool {
+	if h.attr != -1 || h.curr.PrevSibling == nil {
+		return false
+	}
+	h.curr = h.curr.PrevSibling
+	return true
+}
+
+func (h *htmlNavigator) MoveTo(other xpath.NodeNavigator) bool {
+	node, ok := other.(*htmlNavigator)
+	if !ok || node.root != h.root {
+		return false
+	}
+	h.curr, h.attr = node.curr, node.attr
+	return true
+}
+
+type xmlNode struct {
+	kind                xpath.NodeType
+	space, prefix, name string // namespace url, prefix in the document and local name
+	data                string
+	attrs               []xmlAttr
+
+	parent, firstChild, lastChild, prev, next *xmlNode
+}
+
+type xmlAttr struct {
+	space, prefix, name string
+	value               string
+}
+
+func (n *xmlNode) appendChild(child *xmlNode) {
+	child.parent = n
+	if n.lastChild == nil {
+		n.firstChild = child
+	} else {
+		n.lastChild.next = child
+		child.prev = n.lastChild
+	}
+	n.lastChild = child
+}
+
+// parseXML builds the tree of an already decoded document, keeping the prefixes of its namespaces.
+func parseXML(body []byte) (*xmlNode, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(body))
+	decoder.CharsetReader = func(_ string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}
+	root := &xmlNode{kind: xpath.RootNode}
+	curr := root
+	// prefixes declared by the open elements, innermost last
+	var scopes []map[string]string
+	prefixOf := func(space string) string {
+		for i := len(scopes) - 1; i >= 0; i-- {
+			if prefix, ok := scopes[i][space]; ok {
+				return prefix
+			}
+		}
+		return ""
+	}
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return root, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			scope := map[string]string{}
+			var attrs []xml.Attr
+			for _, attr := range t.Attr {
+				switch {
+				case attr.Name.Space == "xmlns":
+					scope[attr.Value] = attr.Name.Local
+				case attr.Name.Space == "" && attr.Name.Local == "xmlns":
+					scope[attr.Value] = ""
+				default:
+					attrs = append(attrs, attr)
+				}
+			}
+			scopes = append(scopes, scope)
+			node := &xmlNode{kind: xpath.ElementNode, space: t.Name.Space, prefix: prefixOf(t.Name.Space), name: t.Name.Local}
+			for _, attr := range attrs {
+				node.attrs = append(node.attrs, xmlAttr{space: attr.Name.Space, prefix: prefixOf(attr.Name.Space),
+					name: attr.Name.Local, value: attr.Value})
+			}
+			curr.appendChild(node)
+			curr = node
+		case xml.EndElement:
+			scopes = scopes[:len(scopes)-1]
+			curr = curr.parent
+		case xml.CharData:
+			curr.appendChild(&xmlNode{kind: xpath.TextNode, data: string(t)})
+		case xml.Comment:
+			curr.appendChild(&xmlNode{kind: xpath.CommentNode, data: string(t)})
+		}
+	}
+}
+
+type xmlNavigator struct {
+	doc  *XMLDocument
+	curr *xmlNode
+	attr int
+}
+
+func (x *xmlNavigator) NodeType() xpath.NodeType {
+	if x.attr != -1 {
+		return xpath.AttributeNode
+	}
+	return x.curr.kind
+}
+
+func (x *xmlNavigator) LocalName() string {
+	if x.attr != -1 {
+		return x.curr.attrs[x.attr].name
+	}
+	return x.curr.name
+}
+
+func (x *xmlNavigator) Prefix() string {
+	space, prefix := x.curr.space, x.curr.prefix
+	if x.attr != -1 {
+		space, prefix = x.curr.attrs[x.attr].space, x.curr.attrs[x.attr].prefix
+	}
+	if mapped, ok := x.doc.namespaces[space]; ok && space != "" {
+		return mapped
+	}
+	return prefix
+}
+
+// NamespaceURL is used by the namespace-uri() function.
+func (x *xmlNavigator) NamespaceURL() string {
+	if x.attr != -1 {
+		return x.curr.attrs[x.attr].space
+	}
+	return x.curr.space
+}
+
+func (x *xmlNavigator) Value() string {
+	if x.attr != -1 {
+		return x.curr.attrs[x.attr].value
+	}
+	if x.curr.kind == xpath.TextNode || x.curr.kind == xpath.CommentNode {
+		return x.curr.data
+	}
+	var b strings.Builder
+	var collect func(n *xmlNode)
+	collect = func(n *xmlNode) {
+		if n.kind == xpath.TextNode {
+			b.WriteString(n.data)
+		}
+		for child := n.firstChild; child != nil; child = child.next {
+			collect(child)
+		}
+	}
+	collect(x.curr)
+	return b.String()
+}
+
+func (x *xmlNavigator) Copy() xpath.NodeNavigator {
+	n := *x
+	return &n
+}
+
+func (x *xmlNavigator) MoveToRoot() {
+	x.curr, x.attr = x.doc.root, -1
+}
+
+func (x *xmlNavigator) MoveToParent() bool {
+	if x.attr != -1 {
+		x.attr = -1
+		return true
+	}
+	if x.curr.parent == nil {
+		return false
+	}
+	x.curr = x.curr.parent
+	return true
+}
+
+func (x *xmlNavigator) MoveToNextAttribute() bool {
+	if x.attr >= len(x.curr.attrs)-1 {
+		return false
+	}
+	x.attr++
+	return true
+}
+
+func (x *xmlNavigator) MoveToChild() bool {
+	if x.attr != -1 || x.curr.firstChild == nil {
+		return false
+	}
+	x.curr = x.curr.firstChild
+	return true
+}
+
+func (x *xmlNavigator) MoveToFirst() bool {
+	if x.attr != -1 || x.curr.prev == nil {
+		return false
+	}
+	for x.curr.prev != nil {
+		x.curr = x.curr.prev
+	}
+	return true
+}
+
+func (x *xmlNavigator) MoveToNext() bool {
+	if x.attr != -1 || x.curr.next == nil {
+		return false
+	}
+	x.curr = x.curr.next
+	return true
+}
+
+func (x *xmlNavigator) MoveToPrevious() bool {
+	if x.attr != -1 || x.curr.prev == nil {
+		return false
+	}
+	x.curr = x.curr.prev
+	return true
+}
+
+func (x *xmlNavigator) MoveTo(other xpath.NodeNavigator) bool {
+	node, ok := other.(*xmlNavigator)
+	if !ok || node.doc != x.doc {
+		return false
+	}
+	x.curr, x.attr = node.curr, node.attr
+	return true
+}